@@ -128,18 +128,340 @@ typedef enum {
 	PionSignalingStateClosed
 } PionSignalingState;
 
+typedef enum {
+	PionTransceiverDirectionUnknown,
+
+	// RTPTransceiverDirectionSendrecv indicates the RTPSender will offer
+	// to send RTP and the RTPReceiver will offer to receive RTP.
+	PionTransceiverDirectionSendrecv,
+
+	// RTPTransceiverDirectionSendonly indicates the RTPSender will offer
+	// to send RTP.
+	PionTransceiverDirectionSendonly,
+
+	// RTPTransceiverDirectionRecvonly indicates the RTPReceiver will
+	// offer to receive RTP.
+	PionTransceiverDirectionRecvonly,
+
+	// RTPTransceiverDirectionInactive indicates the RTPSender won't offer
+	// to send RTP and the RTPReceiver won't offer to receive RTP.
+	PionTransceiverDirectionInactive
+} PionTransceiverDirection;
+
+// Type of a session description, passed to local_description_callback and
+// pionSetRemoteDescriptionWithType
+typedef enum {
+	PionSdpTypeUnknown = 0,
+	PionSdpTypeOffer,
+	PionSdpTypePranswer,
+	PionSdpTypeAnswer,
+	PionSdpTypeRollback
+} PionSdpType;
+
+// Layers of a received simulcast track passed to track_packet_callback
+typedef enum {
+	// every layer, told apart by PionTrackPacketInfo.rid
+	PionSimulcastLayerAll = 0,
+	// the layer with the requested RID
+	PionSimulcastLayerRid,
+	// the layer with the highest bitrate
+	PionSimulcastLayerHighest,
+	// the active layer with the lowest bitrate
+	PionSimulcastLayerLowest
+} PionSimulcastLayer;
+
+// ICE candidate type
+typedef enum {
+	PionIceCandidateTypeUnknown = 0,
+	PionIceCandidateTypeHost,
+	PionIceCandidateTypeSrflx,
+	PionIceCandidateTypePrflx,
+	PionIceCandidateTypeRelay
+} PionIceCandidateType;
+
+// Network types used for ICE gathering, combined as flags
+typedef enum {
+	// every supported network type
+	PionNetworkTypeDefault = 0,
+	PionNetworkTypeUDP4 = 1 << 0,
+	PionNetworkTypeUDP6 = 1 << 1,
+	PionNetworkTypeTCP4 = 1 << 2,
+	PionNetworkTypeTCP6 = 1 << 3
+} PionNetworkType;
+
+// Media kind of a track or transceiver
+typedef enum {
+	PionTrackKindUnknown = 0,
+	PionTrackKindAudio = 1,
+	PionTrackKindVideo = 2
+} PionTrackKind;
+
+// Remote track metadata passed to remote_track_info_callback.
+// Strings are only valid for the duration of the callback.
 typedef struct {
+	int kind;
+	unsigned int ssrc;
+	const char* mime_type;
+	unsigned int clock_rate;
+	unsigned short channels;
+	const char* track_id;
+	const char* stream_id;
+	const char* rid;
+	const char* mid;
+	unsigned char payload_type;
+	const char* fmtp_line;
+	PionTransceiverDirection direction;
+} PionRemoteTrackInfo;
+
+// Per-packet RTP metadata passed to track_packet_callback.
+typedef struct {
+	unsigned int ssrc;
+	unsigned short sequence_number;
+	unsigned int timestamp;
+	unsigned char payload_type;
+	int marker;
+	// local receive time in microseconds since the Unix epoch
+	int64_t arrival_time_us;
+	// packets missing between the previous packet and this one
+	unsigned short lost_packets;
+	// simulcast layer, NULL for tracks without simulcast
+	const char* rid;
+} PionTrackPacketInfo;
+
+// Credential of a TURN server
+typedef enum {
+	// credential is the password
+	PionIceCredentialTypePassword = 0,
+	// credential is the OAuth access token, mac_key the base64url encoded
+	// MAC key (RFC 7635)
+	PionIceCredentialTypeOauth
+} PionIceCredentialType;
+
+typedef struct {
+    // STUN or TURN URLs of the server, comma separated, e.g.
+    // "stun:stun.example.com:3478", "turn:turn.example.com:3478?transport=tcp"
+    // or "turn:turn.example.com:3478,turns:turn.example.com:5349?transport=tcp"
     const char* hostname;
     const char* username;
 	const char* credential;
+    // PionIceCredentialType
     int credential_type;
+	const char* mac_key;
 } PionIceServer;
 
+// Candidates used by ICE
+typedef enum {
+	// every candidate type
+	PionIceTransportPolicyAll = 0,
+	// only relay candidates, all traffic goes through a TURN server
+	PionIceTransportPolicyRelay
+} PionIceTransportPolicy;
+
+// Media bundling when the remote endpoint is not bundle-aware
+typedef enum {
+	// balanced
+	PionBundlePolicyDefault = 0,
+	PionBundlePolicyBalanced,
+	PionBundlePolicyMaxCompat,
+	PionBundlePolicyMaxBundle
+} PionBundlePolicy;
+
+// Multiplexing of RTCP on the RTP candidates
+typedef enum {
+	// require
+	PionRtcpMuxPolicyDefault = 0,
+	PionRtcpMuxPolicyNegotiate,
+	PionRtcpMuxPolicyRequire
+} PionRtcpMuxPolicy;
+
+// Interceptor selection flags for PionPeerConnectionConfiguration.interceptors.
+// 0 selects the default set (NACK, RTCP reports and TWCC).
+typedef enum {
+	PionInterceptorDefault = 0,
+	// install none of the optional interceptors when used on its own
+	PionInterceptorNone = 1 << 0,
+	// generate NACKs for lost packets and retransmit on NACK
+	PionInterceptorNACK = 1 << 1,
+	// generate RTCP sender and receiver reports
+	PionInterceptorRTCPReports = 1 << 2,
+	// transport-wide congestion control sequence numbers and feedback
+	PionInterceptorTWCC = 1 << 3
+} PionInterceptor;
+
 typedef struct {
 	const PionIceServer* ice_servers;
 	int num_servers;
+
+	// receive jitter buffer, frames are delivered through track_frame_callback
+	// instead of track_data_callback. The delay adapts to the measured jitter
+	// between min and max.
+	int jitter_buffer_enabled;
+	int jitter_buffer_min_delay_ms;
+	int jitter_buffer_max_delay_ms;
+
+	// connection quality monitor, events are delivered through quality_callback
+	int quality_monitor_enabled;
+	int quality_monitor_interval_ms;
+	float quality_max_packet_loss_percent;
+	int quality_max_jitter_ms;
+	int quality_max_rtt_ms;
+	int quality_min_packet_rate;
+
+	// combination of PionInterceptor flags
+	int interceptors;
+
+	// send side bandwidth estimation (GCC), bitrates in bits per second,
+	// estimates are delivered through target_bitrate_callback
+	int bandwidth_estimation_enabled;
+	int bwe_initial_bitrate;
+	int bwe_min_bitrate;
+	int bwe_max_bitrate;
+
+	// direction of the default audio transceiver, PionTransceiverDirectionUnknown
+	// selects sendrecv. Use recvonly for listener-only clients.
+	PionTransceiverDirection audio_direction;
+
+	// perfect negotiation: offers and answers are created automatically and
+	// offer collisions are resolved, the host forwards every local description
+	// to the remote and every remote description to pionSetRemoteDescriptionWithType.
+	// Exactly one of the two peers sets polite.
+	int perfect_negotiation;
+	int polite;
+
+	// layers of received simulcast tracks that are delivered, can be changed
+	// per track with pionSelectSimulcastLayer
+	PionSimulcastLayer simulcast_layer;
+
+	// UDP port range for ICE, 0 selects ephemeral ports
+	unsigned short ice_port_min;
+	unsigned short ice_port_max;
+	// serve the ICE traffic of all connections from this single UDP port,
+	// 0 disables it. The port range is not used with the mux.
+	int ice_udp_mux_port;
+
+	// public addresses of a static NAT, comma separated, e.g.
+	// "203.0.113.4,2001:db8::4" or "203.0.113.4/10.0.0.4" to map a single
+	// local address. NULL disables the mapping.
+	const char* nat_1to1_ips;
+	// PionIceCandidateTypeHost (default) replaces the local addresses in host
+	// candidates, PionIceCandidateTypeSrflx adds srflx candidates instead and
+	// takes one public address per IP family without local addresses
+	PionIceCandidateType nat_1to1_candidate_type;
+
+	// combination of PionNetworkType flags
+	int ice_network_types;
+	// network interfaces used for gathering, comma separated name patterns
+	// such as "eth*". NULL allows every interface that is not denied.
+	const char* ice_interface_allow_list;
+	const char* ice_interface_deny_list;
+	// local addresses used for gathering, comma separated CIDR ranges or
+	// IPs such as "10.0.0.0/8,fd00::1". NULL allows every address that is
+	// not denied.
+	const char* ice_ip_allow_list;
+	const char* ice_ip_deny_list;
+
+	// ICE timeouts, 0 keeps the default given in parentheses.
+	// Time without traffic before the connection is disconnected (5000)
+	int ice_disconnected_timeout_ms;
+	// time in the disconnected state before the connection fails (25000)
+	int ice_failed_timeout_ms;
+	// interval of the consent checks while there is no other traffic (2000)
+	int ice_keepalive_interval_ms;
+	// minimum time before a pair with a candidate of the type is nominated,
+	// host (0), srflx (500), prflx (1000) and relay (2000)
+	int ice_host_acceptance_min_wait_ms;
+	int ice_srflx_acceptance_min_wait_ms;
+	int ice_prflx_acceptance_min_wait_ms;
+	int ice_relay_acceptance_min_wait_ms;
+
+	// TCP port of passive ICE-TCP candidates for networks that block UDP,
+	// 0 disables them. Shared by all connections like the UDP mux.
+	int ice_tcp_mux_port;
+
+	// run an ICE-lite agent that only gathers host candidates, for servers
+	// with a public address. The remote peer must run full ICE.
+	int ice_lite;
+
+	PionIceTransportPolicy ice_transport_policy;
+	PionBundlePolicy bundle_policy;
+	PionRtcpMuxPolicy rtcp_mux_policy;
+
+	// TURN server with credentials generated from a shared secret ("TURN
+	// REST API", coturn use-auth-secret). turn_rest_urls are comma separated
+	// TURN URLs, the credentials are renewed before they expire. NULL urls
+	// or secret disable it.
+	const char* turn_rest_urls;
+	const char* turn_rest_secret;
+	// appended to the expiry time in the username, may be NULL
+	const char* turn_rest_user_id;
+	// lifetime of the credentials, 0 selects 86400 (24 hours)
+	int turn_rest_ttl_s;
+
+	// add the embedded TURN server started with pionStartTurnServer to the
+	// ICE servers, as STUN and TURN server
+	int use_turn_server;
+
+	// DTLS certificate and PKCS #8 private key as PEM, e.g. from
+	// pionGenerateCertificate, so the fingerprint stays the same across
+	// sessions. NULL generates a new certificate per connection.
+	const char* certificate_pem;
+
+	// pass local descriptions with the gathered candidates to
+	// local_description_callback, for hosts that don't forward the
+	// candidates of ice_candidate_callback
+	int local_description_with_candidates;
 } PionPeerConnectionConfiguration;
 
+// Embedded TURN server, started with pionStartTurnServer. Clients log in with
+// username and password, or with TURN REST credentials made from auth_secret.
+typedef struct {
+	// UDP address to listen on, NULL selects "0.0.0.0:3478"
+	const char* listen_address;
+	// also accept TURN over TCP on the same address
+	int tcp;
+	// NULL selects "pion"
+	const char* realm;
+	const char* username;
+	const char* password;
+	// shared secret for TURN REST API credentials, may be NULL
+	const char* auth_secret;
+	// IP announced in relay candidates, must be reachable by the peers
+	const char* relay_address;
+	// range of the relay ports, 0 selects ephemeral ports
+	unsigned short relay_port_min;
+	unsigned short relay_port_max;
+} PionTurnServerConfiguration;
+
+// RTCP message used to request a keyframe
+typedef enum {
+	// Picture Loss Indication (RFC 4585)
+	PionKeyframeRequestPLI = 0,
+	// Full Intra Request (RFC 5104)
+	PionKeyframeRequestFIR
+} PionKeyframeRequestType;
+
+// Connection quality event passed to quality_callback. The reason lists the
+// metrics that crossed their thresholds or is "recovered".
+typedef struct {
+	int score;
+	int degraded;
+	const char* reason;
+	float packet_loss_percent;
+	int jitter_ms;
+	int rtt_ms;
+	int packet_rate;
+} PionQualityEvent;
+
+// Frame released by the receive jitter buffer and passed to track_frame_callback.
+// When lost is set no payload is passed and the decoder should conceal the frame.
+typedef struct {
+	unsigned int ssrc;
+	unsigned short sequence_number;
+	unsigned int timestamp;
+	int lost;
+	unsigned int target_delay_ms;
+} PionTrackFrameInfo;
+
 // Example of function declaration in C
 extern void onMessage(uint8_t* msg, int len);
 extern void onIceCandidate(const char* candidate);
@@ -164,16 +486,56 @@ static void helper_local_description(localdescriptioncb f, int type, const char*
 typedef void (*remotetrackcb)(int, unsigned int, const char*, unsigned int, unsigned short);
 static void helper_remote_track(remotetrackcb f, int kind, unsigned int ssrc, const char* mime, unsigned int sample_rate, unsigned short channels) { f(kind, ssrc, mime, sample_rate, channels); }
 
+// helper to call new track callback with full track metadata
+typedef void (*remotetrackinfocb)(const PionRemoteTrackInfo*);
+static void helper_remote_track_info(remotetrackinfocb f, const PionRemoteTrackInfo* info) { f(info); }
+
+// helper to call track ended callback
+typedef void (*remotetrackendedcb)(unsigned int, const char*);
+static void helper_remote_track_ended(remotetrackendedcb f, unsigned int ssrc, const char* track_id) { f(ssrc, track_id); }
+
 // helper to call new track callback
 typedef void (*trackdatacb)(unsigned int, const char*, unsigned int);
 static void helper_track_data(trackdatacb f, unsigned int ssrc, const char* data, unsigned int length) { f(ssrc, data, length); }
 
+// helper to call track packet callback
+typedef void (*trackpacketcb)(const PionTrackPacketInfo*, const char*, unsigned int);
+static void helper_track_packet(trackpacketcb f, const PionTrackPacketInfo* info, const char* data, unsigned int length) { f(info, data, length); }
+
+// helper to call jitter buffer frame callback
+typedef void (*trackframecb)(const PionTrackFrameInfo*, const char*, unsigned int);
+static void helper_track_frame(trackframecb f, const PionTrackFrameInfo* info, const char* data, unsigned int length) { f(info, data, length); }
+
+// helper to call connection quality callback
+typedef void (*qualitycb)(const PionQualityEvent*);
+static void helper_quality(qualitycb f, const PionQualityEvent* event) { f(event); }
+
+// helper to call target bitrate callback
+typedef void (*targetbitratecb)(int);
+static void helper_target_bitrate(targetbitratecb f, int bitrate) { f(bitrate); }
+
+// helper to call keyframe requested callback
+typedef void (*keyframerequestcb)(unsigned int, PionKeyframeRequestType);
+static void helper_keyframe_request(keyframerequestcb f, unsigned int ssrc, PionKeyframeRequestType type) { f(ssrc, type); }
+
+// helper to call negotiation needed callback
+typedef void (*negotiationneededcb)();
+static void helper_negotiation_needed(negotiationneededcb f) { f(); }
+
 typedef struct {
 	logcb log_callback;
 	icecandidatecb ice_candidate_callback;
 	localdescriptioncb local_description_callback;
 	remotetrackcb remote_track_callback;
 	trackdatacb track_data_callback;
+	remotetrackinfocb remote_track_info_callback;
+	remotetrackendedcb remote_track_ended_callback;
+	trackpacketcb track_packet_callback;
+	trackframecb track_frame_callback;
+	qualitycb quality_callback;
+	targetbitratecb target_bitrate_callback;
+	keyframerequestcb keyframe_request_callback;
+	negotiationneededcb negotiation_needed_callback;
 } PionCallbacks;
 
 #line 1 "cgo-generated-wrapper"
@@ -236,16 +598,42 @@ extern void pionInit();
 extern void pionSetCallbacks(PionCallbacks cb);
 extern void pionClosePeerConnection();
 extern GoInt pionCreatePeerConnection(PionPeerConnectionConfiguration* config);
+extern void pionSetIceServers(PionIceServer* servers, int numServers, int restart);
+extern void pionCreateTurnRestCredentials(char* secret, char* userId, int ttlS, char** username, char** credential);
+extern GoInt pionStartTurnServer(PionTurnServerConfiguration* config);
+extern void pionStopTurnServer();
 extern GoInt32 pionCreateDataChannel(char* label);
 extern PionConnectionState pionGetConnectionState();
 extern PionIceGatheringState pionGetIceGatheringState();
 extern PionSignalingState pionGetSignalingState();
 extern void pionCreateOffer();
 extern void pionSetRemoteDescription(char* sdp);
+extern void pionCreateAnswer();
+extern void pionSetRemoteDescriptionWithType(PionSdpType sdpType, char* sdp);
 extern void pionAddICECandidate(char* candidate);
 extern void pionSendDataChannelText(GoInt32 channel, char* msg);
 extern PionDataChannelState pionGetDataChannelReadyState(GoInt32 channel);
 extern void pionSendTrackDataPacket(char* data, int length);
+extern GoInt32 pionAddTransceiver(PionTrackKind kind, PionTransceiverDirection direction);
+extern void pionSetTransceiverDirection(GoInt32 id, PionTransceiverDirection direction);
+extern PionTransceiverDirection pionGetTransceiverDirection(GoInt32 id);
+extern void pionRemoveTrack(GoInt32 id);
+extern GoInt32 pionAddSimulcastTransceiver(char* rids);
+extern void pionSendSimulcastSample(GoInt32 id, char* rid, char* data, int length, int durationUs);
+extern void pionSelectSimulcastLayer(char* mid, PionSimulcastLayer layer, char* rid);
+extern void pionReplaceTrack(GoInt32 id, char* trackId);
+extern void pionPauseTrack(GoInt32 id);
+extern void pionResumeTrack(GoInt32 id);
+extern int pionIsTrackPaused(GoInt32 id);
+extern void pionSetTransceiverCodecPreferences(GoInt32 id, char* mimeTypes);
+extern void pionSendTransceiverSample(GoInt32 id, char* data, int length, int durationUs);
+extern void pionRequestKeyframe(unsigned int ssrc, PionKeyframeRequestType requestType);
+extern GoInt32 pionGetTargetBitrate();
+extern char* pionGetStats();
+extern char* pionGenerateCertificate(int validityDays);
+extern char* pionGetCertificate();
+extern char* pionGetCertificateFingerprint(char* pems);
+extern void pionFreeString(char* str);
 
 #ifdef __cplusplus
 }
@@ -0,0 +1,198 @@
+// file: certificate.go
+
+package connection
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+const defaultCertificateValidity = 365 * 24 * time.Hour
+
+// Certificate is a DTLS certificate with its private key. Passing the same
+// certificate to every connection in webrtc.Configuration.Certificates keeps
+// the DTLS fingerprint stable across sessions, so that it can be pinned.
+type Certificate struct {
+	privateKey  crypto.PrivateKey
+	certificate *x509.Certificate
+}
+
+// GenerateCertificate creates a self-signed ECDSA P-256 certificate, the key
+// type browsers use. validity 0 selects one year.
+func GenerateCertificate(validity time.Duration) (*Certificate, error) {
+	if validity < 0 {
+		return nil, errors.New("certificate validity must not be negative")
+	}
+	if validity == 0 {
+		validity = defaultCertificateValidity
+	}
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: "WebRTC"},
+		NotBefore:    now.Add(-24 * time.Hour),
+		NotAfter:     now.Add(validity),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, privateKey.Public(), privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Certificate{privateKey: privateKey, certificate: certificate}, nil
+}
+
+// CertificateFromPEM reads a CERTIFICATE block and a PKCS #8 PRIVATE KEY
+// block, as written by PEM. The format of pion's Certificate.PEM, which
+// base64 encodes the certificate once more, is accepted as well.
+func CertificateFromPEM(pems string) (*Certificate, error) {
+	var certificate *x509.Certificate
+	var privateKey crypto.PrivateKey
+
+	rest := []byte(pems)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		switch block.Type {
+		case "CERTIFICATE":
+			if certificate != nil {
+				continue
+			}
+			c, err := parseCertificateDER(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certificate = c
+		case "PRIVATE KEY":
+			k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			privateKey = k
+		}
+	}
+
+	if certificate == nil {
+		return nil, errors.New("no CERTIFICATE block in PEM")
+	}
+	if privateKey == nil {
+		return nil, errors.New("no PRIVATE KEY block in PEM")
+	}
+
+	return &Certificate{privateKey: privateKey, certificate: certificate}, nil
+}
+
+func parseCertificateDER(der []byte) (*x509.Certificate, error) {
+	certificate, err := x509.ParseCertificate(der)
+	if err == nil {
+		return certificate, nil
+	}
+
+	// pion's Certificate.PEM
+	decoded, decodeErr := base64.StdEncoding.DecodeString(string(der))
+	if decodeErr != nil {
+		return nil, err
+	}
+
+	return x509.ParseCertificate(decoded)
+}
+
+// PEM returns the certificate and its private key as CERTIFICATE and PKCS #8
+// PRIVATE KEY blocks.
+func (c *Certificate) PEM() (string, error) {
+	privateKey, err := x509.MarshalPKCS8PrivateKey(c.privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	var pems strings.Builder
+	if err := pem.Encode(&pems, &pem.Block{Type: "CERTIFICATE", Bytes: c.certificate.Raw}); err != nil {
+		return "", err
+	}
+	if err := pem.Encode(&pems, &pem.Block{Type: "PRIVATE KEY", Bytes: privateKey}); err != nil {
+		return "", err
+	}
+
+	return pems.String(), nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint announced in a=fingerprint,
+// e.g. "sha-256 AB:CD:...".
+func (c *Certificate) Fingerprint() (string, error) {
+	fingerprints, err := c.WebRTCCertificate().GetFingerprints()
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range fingerprints {
+		if f.Algorithm == "sha-256" {
+			return f.Algorithm + " " + strings.ToUpper(f.Value), nil
+		}
+	}
+
+	return "", errors.New("no sha-256 fingerprint")
+}
+
+// Expires returns the end of the validity of the certificate.
+func (c *Certificate) Expires() time.Time {
+	return c.certificate.NotAfter
+}
+
+// WebRTCCertificate returns the certificate for webrtc.Configuration.Certificates.
+func (c *Certificate) WebRTCCertificate() webrtc.Certificate {
+	return webrtc.CertificateFromX509(c.privateKey, c.certificate)
+}
+
+// Certificate returns the DTLS certificate of the connection, either the one
+// it was created with or the one pion generated for it.
+func (conn *WebRTCConnection) Certificate() (*Certificate, error) {
+	peerConnection, err := conn.lockPeerConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.peerConnectionMutex.RUnlock()
+
+	certificates := peerConnection.GetConfiguration().Certificates
+	if len(certificates) == 0 {
+		return nil, errors.New("connection has no certificate")
+	}
+
+	pems, err := certificates[0].PEM()
+	if err != nil {
+		return nil, err
+	}
+
+	return CertificateFromPEM(pems)
+}
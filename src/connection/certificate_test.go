@@ -0,0 +1,36 @@
+// file: certificate_test.go
+
+package connection
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pion/webrtc/v4"
+)
+
+func TestConnectionCertificateAfterClose(t *testing.T) {
+	certificate, err := GenerateCertificate(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := newTestConnection(t, webrtc.Configuration{
+		Certificates: []webrtc.Certificate{certificate.WebRTCCertificate()},
+	}, WebRTCSettings{}, testCallbacks(t))
+
+	exported, err := conn.Certificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := certificate.Fingerprint()
+	if got, _ := exported.Fingerprint(); got != want {
+		t.Fatalf("exported fingerprint %q, want %q", got, want)
+	}
+
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Certificate(); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("Certificate after Close returned %v", err)
+	}
+}
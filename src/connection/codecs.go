@@ -0,0 +1,76 @@
+// file: codecs.go
+
+package connection
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// videoRTCPFeedback negotiates FIR keyframe requests, NACK and PLI are added
+// by registerInterceptors
+var videoRTCPFeedback = []webrtc.RTCPFeedback{{Type: webrtc.TypeRTCPFBCCM, Parameter: "fir"}}
+
+// supportedCodecs lists the codecs registered with the media engine, in the
+// order they are offered.
+var supportedCodecs = map[webrtc.RTPCodecType][]webrtc.RTPCodecParameters{
+	webrtc.RTPCodecTypeAudio: {
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2, SDPFmtpLine: "useinbandfec=1;stereo=1;sprop-stereo=1;maxaveragebitrate=96000", RTCPFeedback: nil},
+			PayloadType:        111,
+		},
+	},
+	webrtc.RTPCodecTypeVideo: {
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000, RTCPFeedback: videoRTCPFeedback},
+			PayloadType:        96,
+		},
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP9, ClockRate: 90000, SDPFmtpLine: "profile-id=0", RTCPFeedback: videoRTCPFeedback},
+			PayloadType:        98,
+		},
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000, SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f", RTCPFeedback: videoRTCPFeedback},
+			PayloadType:        102,
+		},
+	},
+}
+
+func registerCodecs(mediaEngine *webrtc.MediaEngine) error {
+	for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeAudio, webrtc.RTPCodecTypeVideo} {
+		for _, codec := range supportedCodecs[kind] {
+			if err := mediaEngine.RegisterCodec(codec, kind); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// codecsByMimeType looks up the supported codecs of a kind by mime type
+// ("audio/opus", "video/VP8", ...). The result keeps the given order.
+func codecsByMimeType(kind webrtc.RTPCodecType, mimeTypes []string) ([]webrtc.RTPCodecParameters, error) {
+	codecs := []webrtc.RTPCodecParameters{}
+	for _, mimeType := range mimeTypes {
+		mimeType = strings.TrimSpace(mimeType)
+		if mimeType == "" {
+			continue
+		}
+
+		found := false
+		for _, codec := range supportedCodecs[kind] {
+			if strings.EqualFold(codec.MimeType, mimeType) {
+				codecs = append(codecs, codec)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("codec %s is not supported for %s", mimeType, kind)
+		}
+	}
+
+	return codecs, nil
+}
@@ -0,0 +1,276 @@
+// file: helpers_test.go
+
+package connection
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// testCallbacks returns callbacks that ignore everything, tests replace the
+// ones they observe.
+func testCallbacks(t *testing.T) WebRTCCallbacks {
+	return WebRTCCallbacks{
+		IceCandidate:      func(string) {},
+		LocalDescription:  func(int, string) {},
+		RemoteTrackAdded:  func(RemoteTrackInfo) {},
+		RemoteTrackEnded:  func(uint32, string) {},
+		TrackData:         func(uint32, []byte, int) {},
+		NegotiationNeeded: func() {},
+		LogVerbose:        func(message string) { t.Log(message) },
+	}
+}
+
+// newTestConnection creates and initializes a connection, it is closed when
+// the test ends.
+func newTestConnection(t *testing.T, config webrtc.Configuration, settings WebRTCSettings, callbacks WebRTCCallbacks) *WebRTCConnection {
+	t.Helper()
+
+	conn, err := CreatePeerConnection(config, settings, callbacks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// testPeer is one side of an in-process call. Its local descriptions carry
+// the candidates, so forwarding them is all the signaling needed.
+type testPeer struct {
+	name  string
+	conn  *WebRTCConnection
+	descs chan webrtc.SessionDescription
+	sent  atomic.Int32
+
+	logMutex sync.Mutex
+	logs     []string
+}
+
+// newTestPeer creates one side of a call, setup may install the callbacks a
+// test observes.
+func newTestPeer(t *testing.T, name string, config webrtc.Configuration, settings WebRTCSettings, setup ...func(*WebRTCCallbacks)) *testPeer {
+	t.Helper()
+
+	p := &testPeer{name: name, descs: make(chan webrtc.SessionDescription, 16)}
+	callbacks := testCallbacks(t)
+	callbacks.LocalDescription = func(sdpType int, sdp string) {
+		p.sent.Add(1)
+		p.descs <- webrtc.SessionDescription{Type: webrtc.SDPType(sdpType), SDP: sdp}
+	}
+	callbacks.LogVerbose = func(message string) {
+		p.logMutex.Lock()
+		p.logs = append(p.logs, message)
+		p.logMutex.Unlock()
+		t.Log(name + ": " + message)
+	}
+
+	for _, f := range setup {
+		f(&callbacks)
+	}
+
+	settings.CandidatesInDescription = true
+	p.conn = newTestConnection(t, config, settings, callbacks)
+
+	return p
+}
+
+// logged reports whether the peer logged a message containing text.
+func (p *testPeer) logged(text string) bool {
+	return p.logCount(text) > 0
+}
+
+// logCount returns how many messages containing text the peer logged.
+func (p *testPeer) logCount(text string) int {
+	p.logMutex.Lock()
+	defer p.logMutex.Unlock()
+
+	count := 0
+	for _, message := range p.logs {
+		if strings.Contains(message, text) {
+			count++
+		}
+	}
+	return count
+}
+
+// testSignaling forwards the descriptions between two perfect negotiation
+// peers until the test ends. Holding pause delays them.
+type testSignaling struct {
+	pause sync.RWMutex
+	done  chan struct{}
+}
+
+func newTestSignaling(t *testing.T, a, b *testPeer) *testSignaling {
+	s := &testSignaling{done: make(chan struct{})}
+	t.Cleanup(func() { close(s.done) })
+
+	go s.forward(t, a, b)
+	go s.forward(t, b, a)
+
+	return s
+}
+
+func (s *testSignaling) forward(t *testing.T, from, to *testPeer) {
+	for {
+		select {
+		case <-s.done:
+			return
+		case desc := <-from.descs:
+			s.pause.RLock()
+			if err := to.conn.SetRemoteDescriptionWithType(desc.Type, desc.SDP); err != nil {
+				t.Logf("%s -> %s %s: %v", from.name, to.name, desc.Type, err)
+			}
+			s.pause.RUnlock()
+		}
+	}
+}
+
+// connect negotiates the first offer/answer exchange between a and b and
+// waits until they are connected.
+func connect(t *testing.T, a, b *testPeer) {
+	t.Helper()
+
+	go func() {
+		if err := a.conn.CreateOffer(); err != nil {
+			t.Error(err)
+		}
+	}()
+	offer := <-a.descs
+	if err := b.conn.SetRemoteDescriptionWithType(offer.Type, offer.SDP); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		if err := b.conn.CreateAnswer(); err != nil {
+			t.Error(err)
+		}
+	}()
+	answer := <-b.descs
+	if err := a.conn.SetRemoteDescriptionWithType(answer.Type, answer.SDP); err != nil {
+		t.Fatal(err)
+	}
+
+	waitConnected(t, a, b)
+}
+
+func waitConnected(t *testing.T, peers ...*testPeer) {
+	t.Helper()
+
+	waitFor(t, 15*time.Second, "connected", func() bool {
+		for _, p := range peers {
+			if p.conn.ConnectionState() != webrtc.PeerConnectionStateConnected {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func waitFor(t *testing.T, timeout time.Duration, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// selectedPair waits for the nominated candidate pair of the peer.
+func selectedPair(t *testing.T, p *testPeer) CandidatePairStats {
+	t.Helper()
+
+	var pair *CandidatePairStats
+	waitFor(t, 10*time.Second, p.name+" candidate pair", func() bool {
+		stats, err := p.conn.GetStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pair = stats.CandidatePair
+		return pair != nil
+	})
+
+	return *pair
+}
+
+// newTestTURNServer starts a TURN server on the loopback interface, relay
+// candidates of peers behind it connect through loopback as well.
+func newTestTURNServer(t *testing.T, settings TURNServerSettings) *TURNServer {
+	t.Helper()
+
+	settings.ListenAddress = "127.0.0.1:0"
+	settings.RelayAddress = "127.0.0.1"
+	server, err := newTURNServer(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.server.Close() })
+
+	return server
+}
+
+// relayConfiguration only allows relay candidates through servers.
+func relayConfiguration(servers ...webrtc.ICEServer) webrtc.Configuration {
+	return webrtc.Configuration{
+		ICEServers:         servers,
+		ICETransportPolicy: webrtc.ICETransportPolicyRelay,
+	}
+}
+
+// sendAudio feeds the default audio track of the peer with 20 ms frames,
+// like a host does, until the test ends.
+func sendAudio(t *testing.T, p *testPeer) {
+	sendPeriodically(t, 20*time.Millisecond, func() {
+		p.conn.SendTrackDataPacket(make([]byte, 80))
+	})
+}
+
+// sendVideo writes a frame to the local track of a transceiver 30 times a
+// second until the test ends.
+func sendVideo(t *testing.T, p *testPeer, id int32) {
+	const frameDuration = time.Second / 30
+
+	// an H264 IDR slice in Annex B format, the VP8 payloader takes any data
+	frame := append([]byte{0x00, 0x00, 0x00, 0x01, 0x65}, make([]byte, 1000)...)
+	sendPeriodically(t, frameDuration, func() {
+		// samples of a transceiver that does not send are dropped
+		p.conn.WriteTransceiverSample(id, frame, frameDuration)
+	})
+}
+
+// sendPeriodically calls send every interval until the test ends.
+func sendPeriodically(t *testing.T, interval time.Duration, send func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	// the track channel is closed by Close, which runs after this cleanup
+	t.Cleanup(func() {
+		close(done)
+		<-stopped
+	})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				send()
+			}
+		}
+	}()
+}
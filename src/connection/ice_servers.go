@@ -0,0 +1,63 @@
+// file: ice_servers.go
+
+package connection
+
+import (
+	"github.com/pion/webrtc/v4"
+)
+
+// SetICEServers replaces the STUN and TURN servers of the connection, e.g.
+// when TURN credentials are rotated during a long call. With restart the
+// next offer restarts ICE, so that candidates are gathered again and the
+// connection moves to the new allocations. The offer is created like any
+// other renegotiation: automatically with perfect negotiation, otherwise by
+// the host on the NegotiationNeeded callback. Without restart the servers
+// are used by the next restart.
+func (conn *WebRTCConnection) SetICEServers(servers []webrtc.ICEServer, restart bool) error {
+	if conn.settings.Network.Lite {
+		conn.callbacks.LogVerbose("ICE-lite connections do not use ICE servers")
+		servers = nil
+	}
+
+	conn.iceServersMutex.Lock()
+	conn.iceServers = servers
+	err := conn.updateICEServers()
+	conn.iceServersMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	conn.callbacks.LogVerbose("ICE servers updated")
+
+	if restart {
+		conn.restartICE()
+	}
+
+	return nil
+}
+
+// restartICE makes the next offer restart ICE and starts a negotiation.
+func (conn *WebRTCConnection) restartICE() {
+	conn.signalingMutex.Lock()
+	conn.iceRestart = true
+	conn.signalingMutex.Unlock()
+
+	conn.negotiationNeeded()
+}
+
+// updateICEServers hands the servers of the host and the TURN REST server to
+// pion. Must be called with the ICE servers mutex held.
+func (conn *WebRTCConnection) updateICEServers() error {
+	servers := append([]webrtc.ICEServer{}, conn.iceServers...)
+	if conn.turnREST != nil {
+		servers = append(servers, conn.turnREST.iceServer())
+	}
+
+	configuration := conn.peerConnection.GetConfiguration()
+	configuration.ICEServers = servers
+	if err := conn.peerConnection.SetConfiguration(configuration); err != nil {
+		conn.callbacks.LogVerbose("Failed to set the ICE servers: " + err.Error())
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+// file: ice_servers_test.go
+
+package connection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+func TestSetICEServersRestartUsesNewServers(t *testing.T) {
+	remoteServer := newTestTURNServer(t, TURNServerSettings{Username: "user", Password: "pass"})
+	oldServer := newTestTURNServer(t, TURNServerSettings{Username: "user", Password: "old"})
+	newServer := newTestTURNServer(t, TURNServerSettings{Username: "user", Password: "new"})
+	turnServer := func(server *TURNServer, password string) webrtc.ICEServer {
+		return webrtc.ICEServer{URLs: server.turnURLs(), Username: "user", Credential: password}
+	}
+
+	local := newTestPeer(t, "local", relayConfiguration(turnServer(oldServer, "old")), WebRTCSettings{PerfectNegotiation: true})
+	remote := newTestPeer(t, "remote", relayConfiguration(turnServer(remoteServer, "pass")), WebRTCSettings{PerfectNegotiation: true, Polite: true})
+	newTestSignaling(t, local, remote)
+	waitConnected(t, local, remote)
+
+	if err := local.conn.SetICEServers([]webrtc.ICEServer{turnServer(newServer, "new")}, true); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 15*time.Second, "allocation on the new server", func() bool {
+		return newServer.server.AllocationCount() > 0
+	})
+	waitFor(t, 15*time.Second, "restart", func() bool {
+		return local.settled() && remote.settled()
+	})
+
+	// the connection has moved to the new server
+	oldServer.server.Close()
+	time.Sleep(time.Second)
+	waitConnected(t, local, remote)
+	if pair := selectedPair(t, local); pair.Local.CandidateType != "relay" {
+		t.Fatalf("selected a %s candidate", pair.Local.CandidateType)
+	}
+}
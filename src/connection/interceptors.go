@@ -0,0 +1,114 @@
+// file: interceptors.go
+
+package connection
+
+import (
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/interceptor/pkg/gcc"
+	"github.com/pion/webrtc/v4"
+)
+
+const defaultInitialBitrate = 300_000
+
+// InterceptorFlags selects the interceptors installed on a connection.
+// The zero value selects InterceptorsDefault.
+type InterceptorFlags uint32
+
+const (
+	// InterceptorNone installs no optional interceptor when used on its own
+	InterceptorNone InterceptorFlags = 1 << iota
+	// InterceptorNACK generates NACKs for lost packets and retransmits on NACK
+	InterceptorNACK
+	// InterceptorRTCPReports generates sender and receiver reports
+	InterceptorRTCPReports
+	// InterceptorTWCC adds transport-wide sequence numbers to outgoing
+	// packets and generates transport-wide congestion control feedback
+	InterceptorTWCC
+
+	InterceptorsDefault = InterceptorNACK | InterceptorRTCPReports | InterceptorTWCC
+)
+
+// BandwidthEstimationSettings configures the send side congestion controller
+// (Google Congestion Control). Bitrates are in bits per second, zero values
+// select the defaults.
+type BandwidthEstimationSettings struct {
+	Enabled        bool
+	InitialBitrate int
+	MinBitrate     int
+	MaxBitrate     int
+}
+
+func (f InterceptorFlags) resolve() InterceptorFlags {
+	if f == 0 {
+		return InterceptorsDefault
+	}
+	return f &^ InterceptorNone
+}
+
+// registerInterceptors configures the media engine and interceptor registry
+// with the interceptors selected by flags.
+func registerInterceptors(mediaEngine *webrtc.MediaEngine, registry *interceptor.Registry, flags InterceptorFlags) error {
+	flags = flags.resolve()
+
+	if flags&InterceptorNACK != 0 {
+		if err := webrtc.ConfigureNack(mediaEngine, registry); err != nil {
+			return err
+		}
+	} else {
+		// ConfigureNack also negotiates PLI, keyframe requests must work without it
+		mediaEngine.RegisterFeedback(webrtc.RTCPFeedback{Type: webrtc.TypeRTCPFBNACK, Parameter: "pli"}, webrtc.RTPCodecTypeVideo)
+	}
+
+	if flags&InterceptorRTCPReports != 0 {
+		if err := webrtc.ConfigureRTCPReports(registry); err != nil {
+			return err
+		}
+	}
+
+	if flags&InterceptorTWCC != 0 {
+		if err := webrtc.ConfigureTWCCHeaderExtensionSender(mediaEngine, registry); err != nil {
+			return err
+		}
+		if err := webrtc.ConfigureTWCCSender(mediaEngine, registry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// registerBandwidthEstimator installs the GCC congestion controller. It relies
+// on transport-wide congestion control feedback, see InterceptorTWCC.
+// onEstimator is called with the estimator of each new peer connection.
+func registerBandwidthEstimator(registry *interceptor.Registry, settings BandwidthEstimationSettings, onEstimator func(cc.BandwidthEstimator)) error {
+	initialBitrate := settings.InitialBitrate
+	if initialBitrate <= 0 {
+		initialBitrate = defaultInitialBitrate
+	}
+
+	congestionController, err := cc.NewInterceptor(func() (cc.BandwidthEstimator, error) {
+		// The host adapts its encoders to the target bitrate, so packets are not paced here
+		options := []gcc.Option{
+			gcc.SendSideBWEPacer(gcc.NewNoOpPacer()),
+			gcc.SendSideBWEInitialBitrate(initialBitrate),
+		}
+		if settings.MinBitrate > 0 {
+			options = append(options, gcc.SendSideBWEMinBitrate(settings.MinBitrate))
+		}
+		if settings.MaxBitrate > 0 {
+			options = append(options, gcc.SendSideBWEMaxBitrate(settings.MaxBitrate))
+		}
+		return gcc.NewSendSideBWE(options...)
+	})
+	if err != nil {
+		return err
+	}
+
+	congestionController.OnNewPeerConnection(func(_ string, estimator cc.BandwidthEstimator) {
+		onEstimator(estimator)
+	})
+	registry.Add(congestionController)
+
+	return nil
+}
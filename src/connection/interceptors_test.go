@@ -0,0 +1,168 @@
+// file: interceptors_test.go
+
+package connection
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v4"
+)
+
+// mediaSection returns the first m= section of the given kind in an SDP.
+func mediaSection(description, kind string) string {
+	for _, section := range strings.Split(description, "m=")[1:] {
+		if strings.HasPrefix(section, kind) {
+			return section
+		}
+	}
+	return ""
+}
+
+func TestSimulcastHeaderExtensionsNegotiatedOnce(t *testing.T) {
+	for _, flags := range []InterceptorFlags{InterceptorsDefault, InterceptorNone} {
+		conn := newTestConnection(t, webrtc.Configuration{}, WebRTCSettings{Interceptors: flags}, testCallbacks(t))
+		if _, err := conn.AddSimulcastTransceiver([]string{"q", "h", "f"}); err != nil {
+			t.Fatal(err)
+		}
+
+		offer, err := conn.peerConnection.CreateOffer(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		video := mediaSection(offer.SDP, "video")
+		for _, uri := range []string{sdp.SDESMidURI, sdp.SDESRTPStreamIDURI} {
+			if n := strings.Count(video, " "+uri+"\r\n"); n != 1 {
+				t.Errorf("interceptors %d: %s negotiated %d times", flags, uri, n)
+			}
+		}
+	}
+}
+
+func TestInterceptorFlagsNegotiated(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		settings  WebRTCSettings
+		nack      bool
+		transport bool
+	}{
+		{"default", WebRTCSettings{}, true, true},
+		{"none", WebRTCSettings{Interceptors: InterceptorNone}, false, false},
+		{"nack", WebRTCSettings{Interceptors: InterceptorNACK}, true, false},
+		{"reports", WebRTCSettings{Interceptors: InterceptorRTCPReports}, false, false},
+		{"twcc", WebRTCSettings{Interceptors: InterceptorTWCC}, false, true},
+		{"bandwidth estimation", WebRTCSettings{
+			Interceptors:        InterceptorNone,
+			BandwidthEstimation: BandwidthEstimationSettings{Enabled: true},
+		}, false, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			offerer := newTestConnection(t, webrtc.Configuration{}, test.settings, testCallbacks(t))
+			answerer := newTestConnection(t, webrtc.Configuration{}, test.settings, testCallbacks(t))
+			if _, err := offerer.AddTransceiver(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverDirectionSendrecv); err != nil {
+				t.Fatal(err)
+			}
+
+			offer, err := offerer.peerConnection.CreateOffer(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := answerer.peerConnection.SetRemoteDescription(offer); err != nil {
+				t.Fatal(err)
+			}
+			answer, err := answerer.peerConnection.CreateAnswer(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, description := range []webrtc.SessionDescription{offer, answer} {
+				video := mediaSection(description.SDP, "video")
+				if !strings.Contains(video, " nack pli\r\n") {
+					t.Errorf("%s does not negotiate PLI", description.Type)
+				}
+				if got := strings.Contains(video, " nack\r\n"); got != test.nack {
+					t.Errorf("%s negotiates NACK: %v", description.Type, got)
+				}
+				if got := strings.Contains(video, " transport-cc\r\n"); got != test.transport {
+					t.Errorf("%s negotiates transport-cc feedback: %v", description.Type, got)
+				}
+				if got := strings.Contains(video, " "+sdp.TransportCCURI+"\r\n"); got != test.transport {
+					t.Errorf("%s negotiates the transport-wide sequence number: %v", description.Type, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRTCPReportsFlag(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		flags   InterceptorFlags
+		reports bool
+	}{
+		{"enabled", InterceptorsDefault, true},
+		{"disabled", InterceptorNACK | InterceptorTWCC, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			sender := newTestPeer(t, "sender", webrtc.Configuration{}, WebRTCSettings{Interceptors: test.flags})
+			receiver := newTestPeer(t, "receiver", webrtc.Configuration{}, WebRTCSettings{})
+			connect(t, sender, receiver)
+			sendAudio(t, sender)
+
+			var ssrc uint32
+			waitFor(t, 10*time.Second, "inbound track", func() bool {
+				stats, err := receiver.conn.GetStats()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(stats.InboundTracks) == 0 {
+					return false
+				}
+				ssrc = stats.InboundTracks[0].SSRC
+				return true
+			})
+
+			// pion sends a sender report every second
+			reportsSent := func() uint64 {
+				if s := receiver.conn.rtpStreamStats(ssrc); s != nil {
+					return s.RemoteOutboundRTPStreamStats.ReportsSent
+				}
+				return 0
+			}
+			if test.reports {
+				waitFor(t, 5*time.Second, "sender report", func() bool { return reportsSent() > 0 })
+			} else {
+				time.Sleep(3 * time.Second)
+				if n := reportsSent(); n != 0 {
+					t.Fatalf("%d sender reports received", n)
+				}
+			}
+		})
+	}
+}
+
+func TestBandwidthEstimationReportsTargetBitrate(t *testing.T) {
+	var reported atomic.Int64
+	sender := newTestPeer(t, "sender", webrtc.Configuration{}, WebRTCSettings{
+		BandwidthEstimation: BandwidthEstimationSettings{Enabled: true, InitialBitrate: 100_000},
+	}, func(callbacks *WebRTCCallbacks) {
+		callbacks.TargetBitrate = func(bitrate int) { reported.Store(int64(bitrate)) }
+	})
+	receiver := newTestPeer(t, "receiver", webrtc.Configuration{}, WebRTCSettings{})
+
+	if got := sender.conn.TargetBitrate(); got != 100_000 {
+		t.Fatalf("initial target bitrate %d", got)
+	}
+
+	connect(t, sender, receiver)
+	sendAudio(t, sender)
+
+	waitFor(t, 15*time.Second, "target bitrate", func() bool { return reported.Load() != 0 })
+	if got := int64(sender.conn.TargetBitrate()); got == 100_000 {
+		t.Fatalf("target bitrate did not change")
+	}
+}
@@ -0,0 +1,331 @@
+// file: jitter_buffer.go
+
+package connection
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+const (
+	defaultJitterBufferMinDelay = 40 * time.Millisecond
+	defaultJitterBufferMaxDelay = 400 * time.Millisecond
+	defaultFrameDuration        = 20 * time.Millisecond
+
+	// playout slots between two delay adjustments, so that concealed or
+	// skipped frames are spread out
+	jitterBufferAdjustInterval = 5
+)
+
+// JitterBufferSettings configures the optional receive jitter buffer.
+// Zero delays are replaced by sensible defaults.
+type JitterBufferSettings struct {
+	Enabled  bool
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// JitterBufferFrame is a single frame released by the jitter buffer.
+// When Lost is set the packet never arrived in time, or the buffer inserts
+// a slot to grow its delay. Payload is empty and the decoder is expected to
+// run packet loss concealment (or FEC from the next frame) for this slot.
+type JitterBufferFrame struct {
+	SSRC           uint32
+	SequenceNumber uint16
+	Timestamp      uint32
+	Lost           bool
+	Payload        []byte
+	// TargetDelay is the current adaptive playout delay of the buffer
+	TargetDelay time.Duration
+}
+
+type JitterBufferStats struct {
+	FramesDelivered uint64
+	FramesConcealed uint64
+	PacketsLate     uint64
+	PacketsDropped  uint64
+	Jitter          time.Duration
+	TargetDelay     time.Duration
+}
+
+// JitterBuffer reorders received RTP packets by sequence number, holds them
+// for an adaptive target delay and releases one frame per frame duration.
+// During playout the buffered delay follows the target: a concealed slot is
+// inserted when the jitter grows and a frame is skipped when it shrinks.
+type JitterBuffer struct {
+	ssrc      uint32
+	clockRate uint32
+	minDelay  time.Duration
+	maxDelay  time.Duration
+	output    func(JitterBufferFrame)
+
+	mu      sync.Mutex
+	packets map[uint16]*rtp.Packet
+
+	started       bool
+	nextSeq       uint16
+	nextTimestamp uint32
+	lastPushed    *rtp.Packet
+	frameSamples  uint32
+	frameDuration time.Duration
+	concealed     int
+	sinceAdjust   int
+
+	// interarrival jitter estimate in seconds (RFC 3550, 6.4.1)
+	jitter       float64
+	lastTransit  float64
+	haveTransit  bool
+	targetDelay  time.Duration
+	stats        JitterBufferStats
+	done         chan struct{}
+	stopOnce     sync.Once
+	playoutGroup sync.WaitGroup
+}
+
+// NewJitterBuffer creates a jitter buffer for one remote stream and starts
+// its playout loop. Frames are handed to output on the playout goroutine.
+func NewJitterBuffer(ssrc uint32, clockRate uint32, settings JitterBufferSettings, output func(JitterBufferFrame)) *JitterBuffer {
+	minDelay := settings.MinDelay
+	if minDelay <= 0 {
+		minDelay = defaultJitterBufferMinDelay
+	}
+	maxDelay := settings.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultJitterBufferMaxDelay
+	}
+	if maxDelay < minDelay {
+		maxDelay = minDelay
+	}
+	if clockRate == 0 {
+		clockRate = 48000
+	}
+
+	jb := &JitterBuffer{
+		ssrc:          ssrc,
+		clockRate:     clockRate,
+		minDelay:      minDelay,
+		maxDelay:      maxDelay,
+		output:        output,
+		packets:       make(map[uint16]*rtp.Packet),
+		frameDuration: defaultFrameDuration,
+		frameSamples:  uint32(defaultFrameDuration.Seconds() * float64(clockRate)),
+		targetDelay:   minDelay,
+		done:          make(chan struct{}),
+	}
+
+	jb.playoutGroup.Add(1)
+	go jb.playout()
+
+	return jb
+}
+
+// Push inserts a received packet. Duplicates and packets that arrive after
+// their playout slot has passed are dropped.
+func (jb *JitterBuffer) Push(packet *rtp.Packet, arrival time.Time) {
+	jb.mu.Lock()
+	defer jb.mu.Unlock()
+
+	if jb.started && seqBefore(packet.SequenceNumber, jb.nextSeq) {
+		jb.stats.PacketsLate++
+		return
+	}
+	if _, ok := jb.packets[packet.SequenceNumber]; ok {
+		return
+	}
+
+	jb.packets[packet.SequenceNumber] = packet
+	jb.updateJitter(packet, arrival)
+
+	if jb.lastPushed != nil && packet.SequenceNumber == jb.lastPushed.SequenceNumber+1 {
+		samples := packet.Timestamp - jb.lastPushed.Timestamp
+		if samples > 0 && samples < jb.clockRate {
+			jb.frameSamples = samples
+			jb.frameDuration = time.Duration(float64(samples) / float64(jb.clockRate) * float64(time.Second))
+		}
+	}
+	if jb.lastPushed == nil || seqBefore(jb.lastPushed.SequenceNumber, packet.SequenceNumber) {
+		jb.lastPushed = packet
+	}
+}
+
+// Stats returns a snapshot of the jitter buffer counters.
+func (jb *JitterBuffer) Stats() JitterBufferStats {
+	jb.mu.Lock()
+	defer jb.mu.Unlock()
+
+	stats := jb.stats
+	stats.Jitter = time.Duration(jb.jitter * float64(time.Second))
+	stats.TargetDelay = jb.targetDelay
+	return stats
+}
+
+// Stop terminates the playout loop. Buffered packets are discarded.
+func (jb *JitterBuffer) Stop() {
+	jb.stopOnce.Do(func() {
+		close(jb.done)
+	})
+	jb.playoutGroup.Wait()
+}
+
+func (jb *JitterBuffer) updateJitter(packet *rtp.Packet, arrival time.Time) {
+	arrivalSeconds := float64(arrival.UnixNano()) / float64(time.Second)
+	transit := arrivalSeconds - float64(packet.Timestamp)/float64(jb.clockRate)
+	if jb.haveTransit {
+		d := transit - jb.lastTransit
+		if d < 0 {
+			d = -d
+		}
+		// ignore timestamp jumps (e.g. after DTX) that are not network jitter
+		if d < jb.maxDelay.Seconds() {
+			jb.jitter += (d - jb.jitter) / 16
+		}
+	}
+	jb.lastTransit = transit
+	jb.haveTransit = true
+
+	target := jb.frameDuration + time.Duration(4*jb.jitter*float64(time.Second))
+	jb.targetDelay = min(max(target, jb.minDelay), jb.maxDelay)
+}
+
+func (jb *JitterBuffer) playout() {
+	defer jb.playoutGroup.Done()
+
+	next := time.Now()
+	timer := time.NewTimer(defaultFrameDuration)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-jb.done:
+			return
+		case <-timer.C:
+			frame, ok, duration := jb.nextFrame()
+			if ok && jb.output != nil {
+				jb.output(frame)
+			}
+
+			// keep a steady cadence independent of the time spent in output
+			next = next.Add(duration)
+			if wait := time.Until(next); wait > 0 {
+				timer.Reset(wait)
+			} else {
+				next = time.Now()
+				timer.Reset(time.Millisecond)
+			}
+		}
+	}
+}
+
+// nextFrame decides what to release for the current playout slot.
+func (jb *JitterBuffer) nextFrame() (JitterBufferFrame, bool, time.Duration) {
+	jb.mu.Lock()
+	defer jb.mu.Unlock()
+
+	duration := jb.frameDuration
+
+	if !jb.started {
+		if len(jb.packets) == 0 || time.Duration(len(jb.packets))*duration < jb.targetDelay {
+			return JitterBufferFrame{}, false, duration
+		}
+		jb.started = true
+		jb.nextSeq = jb.oldestSequenceNumber()
+		jb.nextTimestamp = jb.packets[jb.nextSeq].Timestamp
+		jb.concealed = 0
+		jb.sinceAdjust = 0
+	}
+
+	// drop the oldest frames when the buffer grew beyond the maximum delay
+	for time.Duration(len(jb.packets))*duration > jb.maxDelay+duration {
+		if _, ok := jb.packets[jb.nextSeq]; ok {
+			delete(jb.packets, jb.nextSeq)
+			jb.stats.PacketsDropped++
+		}
+		jb.nextSeq++
+		jb.nextTimestamp += jb.frameSamples
+	}
+
+	frame := JitterBufferFrame{
+		SSRC:           jb.ssrc,
+		SequenceNumber: jb.nextSeq,
+		Timestamp:      jb.nextTimestamp,
+		TargetDelay:    jb.targetDelay,
+	}
+
+	if jb.adjustDelay(duration) {
+		// conceal one slot and play the next packet one frame later
+		frame.Lost = true
+		jb.stats.FramesConcealed++
+		return frame, true, duration
+	}
+
+	if packet, ok := jb.packets[jb.nextSeq]; ok {
+		delete(jb.packets, jb.nextSeq)
+		frame.Timestamp = packet.Timestamp
+		frame.Payload = packet.Payload
+		jb.nextTimestamp = packet.Timestamp + jb.frameSamples
+		jb.concealed = 0
+		jb.stats.FramesDelivered++
+	} else {
+		frame.Lost = true
+		jb.nextTimestamp += jb.frameSamples
+		jb.concealed++
+		jb.stats.FramesConcealed++
+
+		// the stream stalled; rebuffer instead of concealing forever
+		if len(jb.packets) == 0 && time.Duration(jb.concealed)*duration >= jb.maxDelay {
+			jb.started = false
+		}
+	}
+	jb.nextSeq++
+
+	return frame, true, duration
+}
+
+// adjustDelay moves the buffered delay one frame towards the target delay.
+// A frame is skipped when the buffer holds too much, true is returned when
+// a slot has to be inserted because it holds too little.
+func (jb *JitterBuffer) adjustDelay(duration time.Duration) bool {
+	jb.sinceAdjust++
+	if jb.sinceAdjust < jitterBufferAdjustInterval {
+		return false
+	}
+	// only adjust while packets flow, losses are concealed anyway
+	if _, ok := jb.packets[jb.nextSeq]; !ok {
+		return false
+	}
+
+	buffered := time.Duration(len(jb.packets)) * duration
+	switch {
+	case buffered+duration <= jb.targetDelay:
+		jb.sinceAdjust = 0
+		return true
+	case buffered >= jb.targetDelay+2*duration:
+		delete(jb.packets, jb.nextSeq)
+		jb.stats.PacketsDropped++
+		jb.nextSeq++
+		jb.nextTimestamp += jb.frameSamples
+		jb.sinceAdjust = 0
+	}
+
+	return false
+}
+
+func (jb *JitterBuffer) oldestSequenceNumber() uint16 {
+	first := true
+	var oldest uint16
+	for seq := range jb.packets {
+		if first || seqBefore(seq, oldest) {
+			oldest = seq
+			first = false
+		}
+	}
+	return oldest
+}
+
+// seqBefore reports whether sequence number a precedes b, taking wrap
+// around of the 16 bit sequence space into account.
+func seqBefore(a, b uint16) bool {
+	return a != b && b-a < 0x8000
+}
@@ -0,0 +1,105 @@
+// file: jitter_buffer_test.go
+
+package connection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// newTestJitterBuffer returns a buffer without playout goroutine, the test
+// drives the playout slots through nextFrame.
+func newTestJitterBuffer() *JitterBuffer {
+	jb := NewJitterBuffer(1, 48000, JitterBufferSettings{Enabled: true}, nil)
+	jb.Stop()
+	return jb
+}
+
+func pushTestPacket(jb *JitterBuffer, seq uint16, arrival time.Time) {
+	jb.Push(&rtp.Packet{
+		Header:  rtp.Header{SequenceNumber: seq, Timestamp: uint32(seq) * 960},
+		Payload: []byte{byte(seq)},
+	}, arrival)
+}
+
+func TestJitterBufferGrowsDelayWithJitter(t *testing.T) {
+	jb := newTestJitterBuffer()
+	start := time.Now()
+
+	delivered := 0
+	inserted := 0
+	seq := uint16(0)
+	for slot := 0; slot < 200; slot++ {
+		arrival := start.Add(time.Duration(slot) * defaultFrameDuration)
+		// from slot 50 on every other packet is 60 ms late
+		if slot >= 50 && slot%2 == 1 {
+			arrival = arrival.Add(60 * time.Millisecond)
+		}
+		pushTestPacket(jb, seq, arrival)
+		seq++
+
+		frame, ok, _ := jb.nextFrame()
+		if !ok {
+			continue
+		}
+		if frame.Lost {
+			inserted++
+		} else {
+			delivered++
+		}
+	}
+
+	stats := jb.Stats()
+	if stats.TargetDelay <= defaultJitterBufferMinDelay {
+		t.Fatalf("target delay %v did not grow with jitter", stats.TargetDelay)
+	}
+	if inserted == 0 {
+		t.Fatal("no slot was inserted to grow the delay")
+	}
+	if stats.PacketsDropped != 0 || stats.PacketsLate != 0 {
+		t.Fatalf("packets were dropped (%d) or late (%d)", stats.PacketsDropped, stats.PacketsLate)
+	}
+	// the frame of the last slot has already left the buffer
+	buffered := time.Duration(len(jb.packets)+1) * defaultFrameDuration
+	if buffered+defaultFrameDuration < stats.TargetDelay {
+		t.Fatalf("buffered %v is below the target delay %v", buffered, stats.TargetDelay)
+	}
+	if delivered+len(jb.packets) != int(seq) {
+		t.Fatalf("%d delivered and %d buffered of %d packets", delivered, len(jb.packets), seq)
+	}
+}
+
+func TestJitterBufferShrinksDelayAfterBurst(t *testing.T) {
+	jb := newTestJitterBuffer()
+	start := time.Now()
+
+	seq := uint16(0)
+	for slot := 0; slot < 20; slot++ {
+		pushTestPacket(jb, seq, start.Add(time.Duration(slot)*defaultFrameDuration))
+		seq++
+		jb.nextFrame()
+	}
+
+	// a burst of 200 ms arrives at once, e.g. after a network stall
+	for i := 0; i < 10; i++ {
+		pushTestPacket(jb, seq, start.Add(20*defaultFrameDuration))
+		seq++
+	}
+
+	for slot := 21; slot < 120; slot++ {
+		pushTestPacket(jb, seq, start.Add(time.Duration(slot)*defaultFrameDuration))
+		seq++
+		jb.nextFrame()
+	}
+
+	stats := jb.Stats()
+	if stats.PacketsDropped == 0 {
+		t.Fatal("no frame was skipped to reduce the delay")
+	}
+	buffered := time.Duration(len(jb.packets)) * defaultFrameDuration
+	if buffered >= stats.TargetDelay+2*defaultFrameDuration {
+		t.Fatalf("buffered %v did not return to the target delay %v", buffered, stats.TargetDelay)
+	}
+}
@@ -0,0 +1,132 @@
+// file: negotiation.go
+
+package connection
+
+import (
+	"errors"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// Perfect negotiation as described in the W3C WebRTC specification, section
+// 10.7. Both peers may start a negotiation at any time. When their offers
+// collide the polite peer rolls back its own offer, answers the remote one
+// and offers again afterwards, while the impolite peer ignores the remote
+// offer and waits for the answer to its own.
+//
+// pion cannot roll back a local offer (have-local-offer has no rollback
+// transition). After the first exchange an offer is therefore sent to the
+// remote without applying it: pion stays in the stable state until the
+// answer arrives, then the offer and the answer are applied together. The
+// polite peer rolls back by dropping its unapplied offer. The first offer is
+// applied right away because ICE gathering starts with it; the polite peer
+// leaves it to the impolite peer, so it cannot collide.
+
+// negotiate sends an offer, or queues one until the current offer/answer
+// exchange has completed.
+func (conn *WebRTCConnection) negotiate() {
+	conn.signalingMutex.Lock()
+	defer conn.signalingMutex.Unlock()
+
+	if conn.settings.Polite && conn.peerConnection.CurrentRemoteDescription() == nil {
+		// pion renegotiates if still needed once the first offer is answered
+		conn.callbacks.LogVerbose("waiting for the first offer of the impolite peer")
+		return
+	}
+
+	if conn.unappliedOffer != nil {
+		// the outstanding offer may miss the latest change
+		conn.offerPending = true
+		return
+	}
+
+	switch conn.peerConnection.SignalingState() {
+	case webrtc.SignalingStateStable:
+		conn.offerPending = false
+		// failures are logged by createOffer
+		conn.createOffer()
+	case webrtc.SignalingStateHaveRemoteOffer, webrtc.SignalingStateHaveLocalPranswer:
+		conn.offerPending = true
+	default:
+		// our own offer is outstanding and already reflects the current state
+	}
+}
+
+// publishUnappliedOffer passes an offer to the LocalDescription callback
+// without applying it, the answer applies both. Must be called with the
+// signaling mutex held.
+func (conn *WebRTCConnection) publishUnappliedOffer(offer webrtc.SessionDescription, iceRestart bool) error {
+	if iceRestart && conn.settings.CandidatesInDescription {
+		// the ICE agent restarted with the offer, offer again once the new
+		// candidates are gathered
+		<-webrtc.GatheringCompletePromise(conn.peerConnection)
+
+		var err error
+		offer, err = conn.peerConnection.CreateOffer(nil)
+		if err != nil {
+			conn.callbacks.LogVerbose("Failed to create an offer: " + err.Error())
+			return err
+		}
+	}
+
+	conn.unappliedOffer = &offer
+	conn.callbacks.LogVerbose("offer created: " + offer.SDP)
+	conn.callbacks.LocalDescription(int(offer.Type), offer.SDP)
+
+	return nil
+}
+
+// handleRemoteDescription applies a remote description with perfect
+// negotiation. Must be called with the signaling mutex held.
+func (conn *WebRTCConnection) handleRemoteDescription(remoteSDP webrtc.SessionDescription) error {
+	offerCollision := remoteSDP.Type == webrtc.SDPTypeOffer &&
+		(conn.unappliedOffer != nil || conn.peerConnection.SignalingState() != webrtc.SignalingStateStable)
+
+	conn.ignoreOffer.Store(!conn.settings.Polite && offerCollision)
+	if conn.ignoreOffer.Load() {
+		conn.callbacks.LogVerbose("offer collision: ignoring remote offer (impolite)")
+		return nil
+	}
+
+	if offerCollision {
+		if conn.unappliedOffer == nil {
+			// only the first offer is applied right away, the polite peer
+			// never sends it
+			err := errors.New("cannot roll back an applied local offer")
+			conn.callbacks.LogVerbose("offer collision: " + err.Error())
+			return err
+		}
+
+		conn.callbacks.LogVerbose("offer collision: rolling back local offer (polite)")
+		conn.unappliedOffer = nil
+		conn.offerPending = true
+	}
+
+	if remoteSDP.Type == webrtc.SDPTypeAnswer && conn.unappliedOffer != nil {
+		offer := *conn.unappliedOffer
+		conn.unappliedOffer = nil
+
+		if err := conn.peerConnection.SetLocalDescription(offer); err != nil {
+			conn.callbacks.LogVerbose("Failed to set the offer: " + err.Error())
+			conn.offerPending = true
+			return err
+		}
+	}
+
+	if err := conn.setRemoteDescription(remoteSDP); err != nil {
+		return err
+	}
+
+	if remoteSDP.Type == webrtc.SDPTypeOffer {
+		if err := conn.createAnswer(); err != nil {
+			return err
+		}
+	}
+
+	if conn.offerPending && conn.peerConnection.SignalingState() == webrtc.SignalingStateStable {
+		conn.callbacks.LogVerbose("sending queued offer")
+		go conn.negotiate()
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+// file: negotiation_test.go
+
+package connection
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// settled reports whether the peer has no negotiation in flight and has
+// negotiated all of sections.
+func (p *testPeer) settled(sections ...string) bool {
+	p.conn.signalingMutex.Lock()
+	defer p.conn.signalingMutex.Unlock()
+
+	if p.conn.unappliedOffer != nil || p.conn.peerConnection.SignalingState() != webrtc.SignalingStateStable {
+		return false
+	}
+	local := p.conn.peerConnection.CurrentLocalDescription()
+	remote := p.conn.peerConnection.CurrentRemoteDescription()
+	if local == nil || remote == nil {
+		return false
+	}
+	for _, section := range sections {
+		if !strings.Contains(local.SDP, section) || !strings.Contains(remote.SDP, section) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPerfectNegotiationRecoversFromGlare(t *testing.T) {
+	impolite := newTestPeer(t, "impolite", webrtc.Configuration{}, WebRTCSettings{PerfectNegotiation: true})
+	polite := newTestPeer(t, "polite", webrtc.Configuration{}, WebRTCSettings{PerfectNegotiation: true, Polite: true})
+	signaling := newTestSignaling(t, impolite, polite)
+
+	// the impolite peer sends the first offer on its own
+	waitConnected(t, impolite, polite)
+	waitFor(t, 10*time.Second, "first negotiation", func() bool {
+		return impolite.settled("m=audio") && polite.settled("m=audio")
+	})
+
+	// both peers renegotiate at the same time, their offers cross
+	signaling.pause.Lock()
+	impoliteSent, politeSent := impolite.sent.Load(), polite.sent.Load()
+	if _, err := impolite.conn.AddTransceiver(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverDirectionSendrecv); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := polite.conn.CreateDataChannel("glare"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 10*time.Second, "both offers", func() bool {
+		return impolite.sent.Load() > impoliteSent && polite.sent.Load() > politeSent
+	})
+	signaling.pause.Unlock()
+
+	waitFor(t, 15*time.Second, "renegotiation after the collision", func() bool {
+		return impolite.settled("m=audio", "m=video", "m=application") && polite.settled("m=audio", "m=video", "m=application")
+	})
+
+	if !impolite.logged("offer collision: ignoring remote offer") {
+		t.Error("the impolite peer saw no collision")
+	}
+	if !polite.logged("offer collision: rolling back local offer") {
+		t.Error("the polite peer did not roll back its offer")
+	}
+	waitConnected(t, impolite, polite)
+}
@@ -0,0 +1,417 @@
+// file: network.go
+
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/ice/v4"
+	"github.com/pion/webrtc/v4"
+)
+
+// NetworkSettings configures the sockets and candidates used for ICE. The
+// zero value keeps the pion defaults.
+type NetworkSettings struct {
+	// PortMin and PortMax restrict the ephemeral UDP ports used for ICE
+	PortMin uint16
+	PortMax uint16
+	// UDPMuxPort serves the ICE traffic of all connections of the process
+	// from this UDP port, 0 disables the mux. The port range is not used
+	// with the mux.
+	UDPMuxPort int
+	// TCPMuxPort is the TCP port of passive ICE-TCP host candidates for
+	// networks that block UDP, 0 disables them. Like the UDP mux it is
+	// shared by all connections using the port.
+	TCPMuxPort int
+	// Lite runs an ICE-lite agent for servers with a public address: only
+	// host candidates are gathered, the ICE servers of the configuration are
+	// ignored and the full agent of the remote peer controls the nomination
+	Lite bool
+	// NAT1To1IPs are the public addresses advertised instead of (host) or in
+	// addition to (srflx) the local addresses behind a static NAT. An entry
+	// is either a public IP or "public/local" to map one local IP, IPv4 and
+	// IPv6 may be mixed.
+	NAT1To1IPs []string
+	// NAT1To1CandidateType is ICECandidateTypeHost (the default) or
+	// ICECandidateTypeSrflx. srflx candidates are gathered on the unspecified
+	// address, so they take public IPs only, one per IP family in use.
+	NAT1To1CandidateType webrtc.ICECandidateType
+
+	// NetworkTypes restricts gathering to the given network types, empty
+	// selects UDP4 and UDP6, plus TCP4 and TCP6 with a TCPMuxPort. With TCP
+	// types but no TCPMuxPort only active ICE-TCP candidates are used,
+	// which connect to the passive candidates of the remote.
+	NetworkTypes []webrtc.NetworkType
+	// InterfaceAllowList and InterfaceDenyList select the network interfaces
+	// used for gathering by name, with shell patterns such as "docker*". An
+	// empty allow list allows every interface that is not denied.
+	InterfaceAllowList []string
+	InterfaceDenyList  []string
+	// IPAllowList and IPDenyList select the local addresses used for
+	// gathering, as CIDR ranges ("10.0.0.0/8") or single IPs
+	IPAllowList []string
+	IPDenyList  []string
+
+	Timeouts ICETimeouts
+}
+
+// ICETimeouts tunes the connectivity checks of ICE, zero values keep the
+// pion defaults (given in parentheses).
+type ICETimeouts struct {
+	// Disconnected is the time without any traffic from the remote before
+	// the connection is disconnected (5 s)
+	Disconnected time.Duration
+	// Failed is the time in the disconnected state before the connection
+	// fails (25 s)
+	Failed time.Duration
+	// Keepalive is the interval of the consent checks while there is no
+	// other traffic (2 s)
+	Keepalive time.Duration
+	// HostAcceptanceMinWait, SrflxAcceptanceMinWait, PrflxAcceptanceMinWait
+	// and RelayAcceptanceMinWait are the minimum times before a pair with a
+	// candidate of the type is nominated (0, 500 ms, 1 s, 2 s), so a pair
+	// of a preferred type that succeeds later can still win
+	HostAcceptanceMinWait  time.Duration
+	SrflxAcceptanceMinWait time.Duration
+	PrflxAcceptanceMinWait time.Duration
+	RelayAcceptanceMinWait time.Duration
+}
+
+// pion defaults of the ICE timeouts, SetICETimeouts only takes all three
+const (
+	defaultICEDisconnectedTimeout = 5 * time.Second
+	defaultICEFailedTimeout       = 25 * time.Second
+	defaultICEKeepaliveInterval   = 2 * time.Second
+)
+
+// UDP and TCP muxes are shared by all connections of the process and stay
+// open
+var (
+	udpMuxMutex sync.Mutex
+	udpMuxes    = map[int]ice.UDPMux{}
+	tcpMuxMutex sync.Mutex
+	tcpMuxes    = map[int]ice.TCPMux{}
+)
+
+// sharedUDPMux returns the mux listening on port, the options only apply
+// when the mux is created by the first connection using the port.
+func sharedUDPMux(port int, options ...ice.UDPMuxFromPortOption) (ice.UDPMux, error) {
+	udpMuxMutex.Lock()
+	defer udpMuxMutex.Unlock()
+
+	if mux, found := udpMuxes[port]; found {
+		return mux, nil
+	}
+
+	mux, err := ice.NewMultiUDPMuxFromPort(port, options...)
+	if err != nil {
+		return nil, err
+	}
+	udpMuxes[port] = mux
+
+	return mux, nil
+}
+
+// sharedTCPMux returns the mux listening on port.
+func sharedTCPMux(port int) (ice.TCPMux, error) {
+	tcpMuxMutex.Lock()
+	defer tcpMuxMutex.Unlock()
+
+	if mux, found := tcpMuxes[port]; found {
+		return mux, nil
+	}
+
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{Port: port})
+	if err != nil {
+		return nil, err
+	}
+	mux := ice.NewTCPMuxDefault(ice.TCPMuxParams{
+		Listener:       listener,
+		ReadBufferSize: 8,
+	})
+	tcpMuxes[port] = mux
+
+	return mux, nil
+}
+
+// newSettingEngine applies the network settings to a pion SettingEngine.
+func newSettingEngine(settings NetworkSettings) (webrtc.SettingEngine, error) {
+	settingEngine := webrtc.SettingEngine{}
+	muxOptions := []ice.UDPMuxFromPortOption{}
+
+	networkTypes := settings.NetworkTypes
+	if settings.TCPMuxPort != 0 {
+		if len(networkTypes) == 0 {
+			networkTypes = []webrtc.NetworkType{
+				webrtc.NetworkTypeUDP4, webrtc.NetworkTypeUDP6,
+				webrtc.NetworkTypeTCP4, webrtc.NetworkTypeTCP6,
+			}
+		} else if !slices.Contains(networkTypes, webrtc.NetworkTypeTCP4) &&
+			!slices.Contains(networkTypes, webrtc.NetworkTypeTCP6) {
+			return settingEngine, errors.New("ICE-TCP mux port needs the TCP4 or TCP6 network type")
+		}
+
+		mux, err := sharedTCPMux(settings.TCPMuxPort)
+		if err != nil {
+			return settingEngine, err
+		}
+		settingEngine.SetICETCPMux(mux)
+	}
+
+	if len(networkTypes) > 0 {
+		settingEngine.SetNetworkTypes(networkTypes)
+
+		muxNetworks := []ice.NetworkType{}
+		for _, networkType := range networkTypes {
+			switch networkType {
+			case webrtc.NetworkTypeUDP4:
+				muxNetworks = append(muxNetworks, ice.NetworkTypeUDP4)
+			case webrtc.NetworkTypeUDP6:
+				muxNetworks = append(muxNetworks, ice.NetworkTypeUDP6)
+			case webrtc.NetworkTypeTCP4, webrtc.NetworkTypeTCP6:
+			default:
+				return settingEngine, fmt.Errorf("invalid network type %d", networkType)
+			}
+		}
+		muxOptions = append(muxOptions, ice.UDPMuxFromPortWithNetworks(muxNetworks...))
+	}
+
+	interfaceFilter, err := newInterfaceFilter(settings.InterfaceAllowList, settings.InterfaceDenyList)
+	if err != nil {
+		return settingEngine, err
+	}
+	if interfaceFilter != nil {
+		settingEngine.SetInterfaceFilter(interfaceFilter)
+		muxOptions = append(muxOptions, ice.UDPMuxFromPortWithInterfaceFilter(interfaceFilter))
+	}
+
+	ipFilter, err := newIPFilter(settings.IPAllowList, settings.IPDenyList)
+	if err != nil {
+		return settingEngine, err
+	}
+	if ipFilter != nil {
+		settingEngine.SetIPFilter(ipFilter)
+		muxOptions = append(muxOptions, ice.UDPMuxFromPortWithIPFilter(ipFilter))
+	}
+
+	if settings.UDPMuxPort != 0 {
+		mux, err := sharedUDPMux(settings.UDPMuxPort, muxOptions...)
+		if err != nil {
+			return settingEngine, err
+		}
+		settingEngine.SetICEUDPMux(mux)
+	} else if settings.PortMin != 0 || settings.PortMax != 0 {
+		if err := settingEngine.SetEphemeralUDPPortRange(settings.PortMin, settings.PortMax); err != nil {
+			return settingEngine, err
+		}
+	}
+
+	if len(settings.NAT1To1IPs) > 0 {
+		candidateType := settings.NAT1To1CandidateType
+		switch candidateType {
+		case webrtc.ICECandidateTypeUnknown:
+			candidateType = webrtc.ICECandidateTypeHost
+		case webrtc.ICECandidateTypeHost, webrtc.ICECandidateTypeSrflx:
+		default:
+			return settingEngine, fmt.Errorf("NAT 1:1 candidate type must be host or srflx, got %s", candidateType)
+		}
+		if settings.Lite && candidateType != webrtc.ICECandidateTypeHost {
+			return settingEngine, errors.New("ICE-lite only gathers host candidates, NAT 1:1 candidate type must be host")
+		}
+
+		ips, err := parseNAT1To1IPs(settings.NAT1To1IPs, candidateType == webrtc.ICECandidateTypeHost)
+		if err != nil {
+			return settingEngine, err
+		}
+		settingEngine.SetNAT1To1IPs(ips, candidateType)
+	}
+
+	settingEngine.SetLite(settings.Lite)
+
+	if err := applyICETimeouts(&settingEngine, settings.Timeouts); err != nil {
+		return settingEngine, err
+	}
+
+	return settingEngine, nil
+}
+
+// applyICETimeouts sets the timeouts that differ from the defaults.
+func applyICETimeouts(settingEngine *webrtc.SettingEngine, timeouts ICETimeouts) error {
+	for _, timeout := range []time.Duration{
+		timeouts.Disconnected, timeouts.Failed, timeouts.Keepalive,
+		timeouts.HostAcceptanceMinWait, timeouts.SrflxAcceptanceMinWait,
+		timeouts.PrflxAcceptanceMinWait, timeouts.RelayAcceptanceMinWait,
+	} {
+		if timeout < 0 {
+			return fmt.Errorf("invalid ICE timeout %s", timeout)
+		}
+	}
+
+	if timeouts.Disconnected != 0 || timeouts.Failed != 0 || timeouts.Keepalive != 0 {
+		disconnected, failed, keepalive := timeouts.Disconnected, timeouts.Failed, timeouts.Keepalive
+		if disconnected == 0 {
+			disconnected = defaultICEDisconnectedTimeout
+		}
+		if failed == 0 {
+			failed = defaultICEFailedTimeout
+		}
+		if keepalive == 0 {
+			keepalive = defaultICEKeepaliveInterval
+		}
+		settingEngine.SetICETimeouts(disconnected, failed, keepalive)
+	}
+
+	if timeouts.HostAcceptanceMinWait != 0 {
+		settingEngine.SetHostAcceptanceMinWait(timeouts.HostAcceptanceMinWait)
+	}
+	if timeouts.SrflxAcceptanceMinWait != 0 {
+		settingEngine.SetSrflxAcceptanceMinWait(timeouts.SrflxAcceptanceMinWait)
+	}
+	if timeouts.PrflxAcceptanceMinWait != 0 {
+		settingEngine.SetPrflxAcceptanceMinWait(timeouts.PrflxAcceptanceMinWait)
+	}
+	if timeouts.RelayAcceptanceMinWait != 0 {
+		settingEngine.SetRelayAcceptanceMinWait(timeouts.RelayAcceptanceMinWait)
+	}
+
+	return nil
+}
+
+// newInterfaceFilter matches interface names against the allow and deny
+// patterns, nil when there is nothing to filter.
+func newInterfaceFilter(allow, deny []string) (func(string) bool, error) {
+	allow = trimList(allow)
+	deny = trimList(deny)
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+
+	for _, pattern := range append(append([]string{}, allow...), deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid interface pattern %q: %w", pattern, err)
+		}
+	}
+
+	matches := func(patterns []string, name string) bool {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, name); matched {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(name string) bool {
+		if len(allow) > 0 && !matches(allow, name) {
+			return false
+		}
+		return !matches(deny, name)
+	}, nil
+}
+
+// newIPFilter matches local addresses against the allowed and denied
+// ranges, nil when there is nothing to filter.
+func newIPFilter(allow, deny []string) (func(net.IP) bool, error) {
+	allowNets, err := parseIPNets(allow)
+	if err != nil {
+		return nil, err
+	}
+	denyNets, err := parseIPNets(deny)
+	if err != nil {
+		return nil, err
+	}
+	if len(allowNets) == 0 && len(denyNets) == 0 {
+		return nil, nil
+	}
+
+	contains := func(nets []*net.IPNet, ip net.IP) bool {
+		for _, n := range nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(ip net.IP) bool {
+		if len(allowNets) > 0 && !contains(allowNets, ip) {
+			return false
+		}
+		return !contains(denyNets, ip)
+	}, nil
+}
+
+func parseIPNets(entries []string) ([]*net.IPNet, error) {
+	nets := []*net.IPNet{}
+	for _, entry := range trimList(entries) {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", entry)
+			}
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+// trimList drops surrounding spaces and empty entries.
+func trimList(entries []string) []string {
+	trimmed := []string{}
+	for _, entry := range entries {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			trimmed = append(trimmed, entry)
+		}
+	}
+
+	return trimmed
+}
+
+// parseNAT1To1IPs validates the NAT 1:1 mappings up front, pion only checks
+// them when gathering starts.
+func parseNAT1To1IPs(entries []string, allowLocalMapping bool) ([]string, error) {
+	ips := []string{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		var isIPv4 []bool
+		for _, address := range strings.Split(entry, "/") {
+			ip := net.ParseIP(address)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid NAT 1:1 address %q", entry)
+			}
+			isIPv4 = append(isIPv4, ip.To4() != nil)
+		}
+		if len(isIPv4) > 2 || (len(isIPv4) == 2 && isIPv4[0] != isIPv4[1]) {
+			return nil, fmt.Errorf("invalid NAT 1:1 mapping %q", entry)
+		}
+		if len(isIPv4) == 2 && !allowLocalMapping {
+			return nil, fmt.Errorf("NAT 1:1 mapping %q of a local address needs host candidates", entry)
+		}
+
+		ips = append(ips, entry)
+	}
+
+	return ips, nil
+}
@@ -0,0 +1,158 @@
+// file: network_test.go
+
+package connection
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/turn/v4"
+	"github.com/pion/webrtc/v4"
+)
+
+func TestFullICEConnectsToICELite(t *testing.T) {
+	full := newTestPeer(t, "full", webrtc.Configuration{}, WebRTCSettings{})
+	lite := newTestPeer(t, "lite", webrtc.Configuration{
+		// ignored by the lite agent
+		ICEServers: []webrtc.ICEServer{{URLs: []string{"stun:192.0.2.1:3478"}}},
+	}, WebRTCSettings{Network: NetworkSettings{Lite: true}})
+
+	connect(t, full, lite)
+
+	answer := lite.conn.peerConnection.CurrentLocalDescription()
+	if !strings.Contains(answer.SDP, "a=ice-lite") {
+		t.Fatal("answer of the lite peer is missing a=ice-lite")
+	}
+	for _, line := range strings.Split(answer.SDP, "\r\n") {
+		if strings.HasPrefix(line, "a=candidate:") && !strings.Contains(line, " typ host") {
+			t.Fatalf("lite peer gathered %q", line)
+		}
+	}
+
+	if pair := selectedPair(t, lite); pair.Local.CandidateType != "host" {
+		t.Fatalf("lite peer selected a %s candidate", pair.Local.CandidateType)
+	}
+}
+
+func TestPassiveICETCP(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	tcp := []webrtc.NetworkType{webrtc.NetworkTypeTCP4}
+	active := newTestPeer(t, "active", webrtc.Configuration{}, WebRTCSettings{Network: NetworkSettings{NetworkTypes: tcp}})
+	passive := newTestPeer(t, "passive", webrtc.Configuration{}, WebRTCSettings{Network: NetworkSettings{TCPMuxPort: port, NetworkTypes: tcp}})
+
+	connect(t, active, passive)
+
+	pair := selectedPair(t, passive)
+	if pair.Local.Protocol != "tcp" || pair.Local.Port != int32(port) {
+		t.Fatalf("passive peer selected %s port %d, want tcp port %d", pair.Local.Protocol, pair.Local.Port, port)
+	}
+}
+
+func TestTURNOverTCP(t *testing.T) {
+	server := newTestTURNServer(t, TURNServerSettings{TCP: true, Username: "user", Password: "pass"})
+	config := relayConfiguration(webrtc.ICEServer{
+		URLs:       []string{"turn:" + server.address() + "?transport=tcp"},
+		Username:   "user",
+		Credential: "pass",
+	})
+
+	a := newTestPeer(t, "a", config, WebRTCSettings{})
+	b := newTestPeer(t, "b", config, WebRTCSettings{})
+	connect(t, a, b)
+
+	if pair := selectedPair(t, a); pair.Local.CandidateType != "relay" {
+		t.Fatalf("selected a %s candidate", pair.Local.CandidateType)
+	}
+}
+
+func TestTURNOverTLS(t *testing.T) {
+	certificate, certificatePEM := testTLSCertificate()
+	// read when the system roots are loaded for the first time, no other
+	// test of the package verifies a TLS certificate
+	certificateFile := filepath.Join(t.TempDir(), "turns.pem")
+	if err := os.WriteFile(certificateFile, certificatePEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SSL_CERT_FILE", certificateFile)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{certificate}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := turn.NewServer(turn.ServerConfig{
+		Realm:       defaultTURNServerRealm,
+		AuthHandler: newTURNAuthHandler(TURNServerSettings{Username: "user", Password: "pass"}),
+		ListenerConfigs: []turn.ListenerConfig{{
+			Listener: listener,
+			RelayAddressGenerator: &turn.RelayAddressGeneratorStatic{
+				RelayAddress: net.ParseIP("127.0.0.1"),
+				Address:      "127.0.0.1",
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	config := relayConfiguration(webrtc.ICEServer{
+		URLs:       []string{"turns:" + listener.Addr().String() + "?transport=tcp"},
+		Username:   "user",
+		Credential: "pass",
+	})
+
+	a := newTestPeer(t, "a", config, WebRTCSettings{})
+	b := newTestPeer(t, "b", config, WebRTCSettings{})
+	connect(t, a, b)
+
+	if pair := selectedPair(t, a); pair.Local.CandidateType != "relay" {
+		t.Fatalf("selected a %s candidate", pair.Local.CandidateType)
+	}
+}
+
+// testTLSCertificate is a self-signed certificate for 127.0.0.1 and its PEM
+// encoding. It is generated once, since the system roots that trust it are
+// only loaded once per process.
+var testTLSCertificate = sync.OnceValues(func() (tls.Certificate, []byte) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, privateKey.Public(), privateKey)
+	if err != nil {
+		panic(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: privateKey},
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+})
@@ -0,0 +1,199 @@
+// file: quality_monitor.go
+
+package connection
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	defaultQualityInterval      = 2 * time.Second
+	defaultQualityMaxPacketLoss = 0.05
+	defaultQualityMaxJitter     = 50 * time.Millisecond
+	defaultQualityMaxRTT        = 400 * time.Millisecond
+
+	// metrics must fall below this share of their threshold to recover,
+	// which keeps the monitor from flapping around a threshold
+	qualityRecoveryRatio = 0.8
+)
+
+// QualityMonitorSettings configures the periodic connection quality monitor.
+// Zero thresholds are replaced by defaults, except MinPacketRate which
+// disables the packet rate check when zero.
+type QualityMonitorSettings struct {
+	Enabled          bool
+	Interval         time.Duration
+	MaxPacketLoss    float64 // fraction of packets, 0..1
+	MaxJitter        time.Duration
+	MaxRoundTripTime time.Duration
+	MinPacketRate    int // packets per second per remote track
+}
+
+// QualityEvent is reported when the connection quality crosses one of the
+// configured thresholds and again when it recovers.
+type QualityEvent struct {
+	// Score goes from 100 (all metrics well below their thresholds) down to
+	// 0 (a metric at twice its threshold)
+	Score         int
+	Degraded      bool
+	Reason        string
+	PacketLoss    float64
+	Jitter        time.Duration
+	RoundTripTime time.Duration
+	PacketRate    int
+}
+
+type qualityMetric struct {
+	name  string
+	value string
+	// ratio of the measured value to its threshold, >= 1 means crossed
+	ratio float64
+}
+
+type qualityMonitor struct {
+	settings QualityMonitorSettings
+	conn     *WebRTCConnection
+	done     chan struct{}
+
+	degraded bool
+	crossed  string
+
+	lastReceived map[uint32]uint64
+	lastLost     map[uint32]int64
+}
+
+func newQualityMonitor(conn *WebRTCConnection, settings QualityMonitorSettings) *qualityMonitor {
+	if settings.Interval <= 0 {
+		settings.Interval = defaultQualityInterval
+	}
+	if settings.MaxPacketLoss <= 0 {
+		settings.MaxPacketLoss = defaultQualityMaxPacketLoss
+	}
+	if settings.MaxJitter <= 0 {
+		settings.MaxJitter = defaultQualityMaxJitter
+	}
+	if settings.MaxRoundTripTime <= 0 {
+		settings.MaxRoundTripTime = defaultQualityMaxRTT
+	}
+
+	return &qualityMonitor{
+		settings:     settings,
+		conn:         conn,
+		done:         make(chan struct{}),
+		lastReceived: make(map[uint32]uint64),
+		lastLost:     make(map[uint32]int64),
+	}
+}
+
+func (m *qualityMonitor) run() {
+	defer m.conn.waitGroup.Done()
+
+	ticker := time.NewTicker(m.settings.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			if m.conn.ConnectionState() != webrtc.PeerConnectionStateConnected {
+				continue
+			}
+			if stats, err := m.conn.GetStats(); err == nil {
+				m.sample(stats)
+			}
+		}
+	}
+}
+
+func (m *qualityMonitor) stop() {
+	close(m.done)
+}
+
+// sample evaluates one stats snapshot and fires QualityChanged on transitions.
+func (m *qualityMonitor) sample(stats ConnectionStats) {
+	event := QualityEvent{PacketRate: -1}
+
+	var received, lost uint64
+	for _, in := range stats.InboundTracks {
+		if in.PacketsReceived >= m.lastReceived[in.SSRC] {
+			received += in.PacketsReceived - m.lastReceived[in.SSRC]
+		}
+		if in.PacketsLost > m.lastLost[in.SSRC] {
+			lost += uint64(in.PacketsLost - m.lastLost[in.SSRC])
+		}
+		m.lastReceived[in.SSRC] = in.PacketsReceived
+		m.lastLost[in.SSRC] = in.PacketsLost
+
+		event.Jitter = max(event.Jitter, time.Duration(in.Jitter*float64(time.Second)))
+		if event.PacketRate < 0 || int(in.PacketRate) < event.PacketRate {
+			event.PacketRate = int(in.PacketRate)
+		}
+	}
+	if received+lost > 0 {
+		event.PacketLoss = float64(lost) / float64(received+lost)
+	}
+
+	for _, out := range stats.OutboundTracks {
+		event.PacketLoss = max(event.PacketLoss, out.FractionLost)
+		event.RoundTripTime = max(event.RoundTripTime, time.Duration(out.RoundTripTime*float64(time.Second)))
+	}
+	if stats.CandidatePair != nil {
+		event.RoundTripTime = max(event.RoundTripTime, time.Duration(stats.CandidatePair.RoundTripTime*float64(time.Second)))
+	}
+
+	metrics := []qualityMetric{
+		{"packet loss", fmt.Sprintf("%.1f%%", event.PacketLoss*100), event.PacketLoss / m.settings.MaxPacketLoss},
+		{"jitter", fmt.Sprintf("%dms", event.Jitter.Milliseconds()), float64(event.Jitter) / float64(m.settings.MaxJitter)},
+		{"rtt", fmt.Sprintf("%dms", event.RoundTripTime.Milliseconds()), float64(event.RoundTripTime) / float64(m.settings.MaxRoundTripTime)},
+	}
+	if m.settings.MinPacketRate > 0 && event.PacketRate >= 0 {
+		ratio := 2.0
+		if event.PacketRate > 0 {
+			ratio = float64(m.settings.MinPacketRate) / float64(event.PacketRate)
+		}
+		metrics = append(metrics, qualityMetric{"packet rate", fmt.Sprintf("%d/s", event.PacketRate), ratio})
+	}
+	if event.PacketRate < 0 {
+		event.PacketRate = 0
+	}
+
+	worst := 0.0
+	crossed := []string{}
+	reasons := []string{}
+	for _, metric := range metrics {
+		worst = max(worst, metric.ratio)
+		if metric.ratio >= 1 {
+			crossed = append(crossed, metric.name)
+			reasons = append(reasons, metric.name+" "+metric.value)
+		}
+	}
+	event.Score = int(min(max(100*(1-worst/2), 0), 100))
+
+	crossedKey := strings.Join(crossed, ",")
+	if len(crossed) > 0 {
+		// only report again when a different set of thresholds is crossed
+		if m.degraded && m.crossed == crossedKey {
+			return
+		}
+		event.Degraded = true
+		event.Reason = strings.Join(reasons, ", ")
+	} else {
+		if !m.degraded || worst >= qualityRecoveryRatio {
+			return
+		}
+		event.Reason = "recovered"
+	}
+
+	m.degraded = event.Degraded
+	m.crossed = crossedKey
+
+	m.conn.callbacks.LogVerbose(fmt.Sprintf("connection quality %d: %s", event.Score, event.Reason))
+	if m.conn.callbacks.QualityChanged != nil {
+		m.conn.callbacks.QualityChanged(event)
+	}
+}
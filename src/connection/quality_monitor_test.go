@@ -0,0 +1,127 @@
+// file: quality_monitor_test.go
+
+package connection
+
+import (
+	"strings"
+	"testing"
+)
+
+// qualityStats is a snapshot with the given round trip time and jitter in
+// seconds and cumulative packet counters of one inbound track.
+func qualityStats(rtt, jitter float64, received uint64, lost int64) ConnectionStats {
+	return ConnectionStats{
+		InboundTracks: []InboundTrackStats{{
+			SSRC:            1,
+			PacketsReceived: received,
+			PacketsLost:     lost,
+			Jitter:          jitter,
+		}},
+		CandidatePair: &CandidatePairStats{RoundTripTime: rtt},
+	}
+}
+
+func TestQualityMonitorSample(t *testing.T) {
+	type step struct {
+		stats ConnectionStats
+		// reason of the expected event, "" when none is expected
+		reason string
+	}
+
+	for _, test := range []struct {
+		name  string
+		steps []step
+	}{
+		{"degraded", []step{
+			{qualityStats(0.1, 0.01, 100, 0), ""},
+			{qualityStats(0.5, 0.01, 200, 0), "rtt 500ms"},
+		}},
+		{"unchanged", []step{
+			{qualityStats(0.5, 0.01, 100, 0), "rtt 500ms"},
+			{qualityStats(0.6, 0.01, 200, 0), ""},
+			{qualityStats(0.45, 0.01, 300, 0), ""},
+		}},
+		{"recovered", []step{
+			{qualityStats(0.5, 0.01, 100, 0), "rtt 500ms"},
+			// below the threshold but above the recovery ratio
+			{qualityStats(0.35, 0.01, 200, 0), ""},
+			{qualityStats(0.3, 0.01, 300, 0), "recovered"},
+			{qualityStats(0.1, 0.01, 400, 0), ""},
+		}},
+		{"flapping", []step{
+			{qualityStats(0.41, 0.01, 100, 0), "rtt 410ms"},
+			{qualityStats(0.39, 0.01, 200, 0), ""},
+			{qualityStats(0.41, 0.01, 300, 0), ""},
+			{qualityStats(0.39, 0.01, 400, 0), ""},
+			{qualityStats(0.2, 0.01, 500, 0), "recovered"},
+			{qualityStats(0.41, 0.01, 600, 0), "rtt 410ms"},
+		}},
+		{"different thresholds crossed", []step{
+			{qualityStats(0.5, 0.01, 100, 0), "rtt 500ms"},
+			{qualityStats(0.5, 0.06, 200, 0), "jitter 60ms, rtt 500ms"},
+			{qualityStats(0.5, 0.06, 300, 0), ""},
+			{qualityStats(0.5, 0.01, 400, 0), "rtt 500ms"},
+		}},
+		{"packet loss of the interval", []step{
+			{qualityStats(0.1, 0.01, 1000, 100), "packet loss 9.1%"},
+			// no new losses
+			{qualityStats(0.1, 0.01, 2000, 100), "recovered"},
+			{qualityStats(0.1, 0.01, 2090, 110), "packet loss 10.0%"},
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var events []QualityEvent
+			conn := &WebRTCConnection{callbacks: WebRTCCallbacks{
+				LogVerbose:     func(message string) { t.Log(message) },
+				QualityChanged: func(event QualityEvent) { events = append(events, event) },
+			}}
+			monitor := newQualityMonitor(conn, QualityMonitorSettings{Enabled: true})
+
+			for i, step := range test.steps {
+				events = nil
+				monitor.sample(step.stats)
+
+				if step.reason == "" {
+					if len(events) != 0 {
+						t.Fatalf("step %d: unexpected event %+v", i, events[0])
+					}
+					continue
+				}
+				if len(events) != 1 {
+					t.Fatalf("step %d: %d events, want %q", i, len(events), step.reason)
+				}
+				event := events[0]
+				if event.Reason != step.reason {
+					t.Fatalf("step %d: reason %q, want %q", i, event.Reason, step.reason)
+				}
+				if event.Degraded != (step.reason != "recovered") {
+					t.Fatalf("step %d: degraded %v", i, event.Degraded)
+				}
+				if event.Degraded && event.Score >= 50 || !event.Degraded && event.Score <= 50 {
+					t.Fatalf("step %d: score %d", i, event.Score)
+				}
+			}
+		})
+	}
+}
+
+func TestQualityMonitorPacketRate(t *testing.T) {
+	var events []QualityEvent
+	conn := &WebRTCConnection{callbacks: WebRTCCallbacks{
+		LogVerbose:     func(message string) { t.Log(message) },
+		QualityChanged: func(event QualityEvent) { events = append(events, event) },
+	}}
+	monitor := newQualityMonitor(conn, QualityMonitorSettings{Enabled: true, MinPacketRate: 40})
+
+	stats := qualityStats(0.1, 0.01, 100, 0)
+	stats.InboundTracks[0].PacketRate = 50
+	monitor.sample(stats)
+
+	stats = qualityStats(0.1, 0.01, 110, 0)
+	stats.InboundTracks[0].PacketRate = 10
+	monitor.sample(stats)
+
+	if len(events) != 1 || !strings.HasPrefix(events[0].Reason, "packet rate 10/s") || events[0].PacketRate != 10 {
+		t.Fatalf("events %+v", events)
+	}
+}
@@ -0,0 +1,137 @@
+// file: rtcp.go
+
+package connection
+
+import (
+	"fmt"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v4"
+)
+
+// KeyframeRequestType selects the RTCP message used to ask for a keyframe.
+type KeyframeRequestType int
+
+const (
+	// KeyframeRequestPLI sends a Picture Loss Indication (RFC 4585)
+	KeyframeRequestPLI KeyframeRequestType = iota
+	// KeyframeRequestFIR sends a Full Intra Request (RFC 5104)
+	KeyframeRequestFIR
+)
+
+func (t KeyframeRequestType) String() string {
+	switch t {
+	case KeyframeRequestPLI:
+		return "pli"
+	case KeyframeRequestFIR:
+		return "fir"
+	default:
+		return "unknown"
+	}
+}
+
+// RequestKeyframe asks the remote sender of the track with the given SSRC
+// for a new keyframe.
+func (conn *WebRTCConnection) RequestKeyframe(ssrc uint32, requestType KeyframeRequestType) error {
+	conn.statsMutex.Lock()
+	_, found := conn.receiveStats[ssrc]
+	conn.statsMutex.Unlock()
+	if !found {
+		return fmt.Errorf("remote track with ssrc %d not found", ssrc)
+	}
+
+	var packet rtcp.Packet
+	switch requestType {
+	case KeyframeRequestPLI:
+		packet = &rtcp.PictureLossIndication{MediaSSRC: ssrc}
+	case KeyframeRequestFIR:
+		// the sequence number is incremented for every new request (RFC 5104, 4.3.1.1)
+		conn.firMutex.Lock()
+		if conn.firSequenceNumbers == nil {
+			conn.firSequenceNumbers = make(map[uint32]uint8)
+		}
+		sequenceNumber := conn.firSequenceNumbers[ssrc]
+		conn.firSequenceNumbers[ssrc] = sequenceNumber + 1
+		conn.firMutex.Unlock()
+
+		packet = &rtcp.FullIntraRequest{
+			MediaSSRC: ssrc,
+			FIR:       []rtcp.FIREntry{{SSRC: ssrc, SequenceNumber: sequenceNumber}},
+		}
+	default:
+		return fmt.Errorf("unknown keyframe request type %d", requestType)
+	}
+
+	conn.callbacks.LogVerbose(fmt.Sprintf("requesting keyframe (%s) for ssrc %d", requestType, ssrc))
+	return conn.peerConnection.WriteRTCP([]rtcp.Packet{packet})
+}
+
+// senderRTCPReader reads the RTCP received for a local track and reports
+// keyframe requests. Reading is also what lets the interceptors (congestion
+// control, sender statistics) see the feedback.
+func (conn *WebRTCConnection) senderRTCPReader(sender *webrtc.RTPSender) {
+	defer conn.waitGroup.Done()
+
+	for {
+		packets, _, err := sender.ReadRTCP()
+		if err != nil {
+			return
+		}
+
+		conn.handleSenderRTCP(packets)
+	}
+}
+
+// simulcastRTCPReader reads the RTCP received for a simulcast layer, the
+// first layer is read by senderRTCPReader.
+func (conn *WebRTCConnection) simulcastRTCPReader(sender *webrtc.RTPSender, rid string) {
+	defer conn.waitGroup.Done()
+
+	for {
+		packets, _, err := sender.ReadSimulcastRTCP(rid)
+		if err != nil {
+			return
+		}
+
+		conn.handleSenderRTCP(packets)
+	}
+}
+
+func (conn *WebRTCConnection) handleSenderRTCP(packets []rtcp.Packet) {
+	for _, packet := range packets {
+		switch p := packet.(type) {
+		case *rtcp.PictureLossIndication:
+			conn.keyframeRequested(p.MediaSSRC, KeyframeRequestPLI)
+		case *rtcp.FullIntraRequest:
+			for _, entry := range p.FIR {
+				conn.keyframeRequested(entry.SSRC, KeyframeRequestFIR)
+			}
+		}
+	}
+}
+
+// receiverRTCPReader drains the RTCP received for a remote track, so that the
+// interceptors see the sender reports of the remote. Simulcast tracks are
+// read by their RID.
+func (conn *WebRTCConnection) receiverRTCPReader(receiver *webrtc.RTPReceiver, rid string) {
+	defer conn.waitGroup.Done()
+
+	for {
+		var err error
+		if rid != "" {
+			_, _, err = receiver.ReadSimulcastRTCP(rid)
+		} else {
+			_, _, err = receiver.ReadRTCP()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (conn *WebRTCConnection) keyframeRequested(ssrc uint32, requestType KeyframeRequestType) {
+	conn.callbacks.LogVerbose(fmt.Sprintf("remote requested keyframe (%s) for ssrc %d", requestType, ssrc))
+	if conn.callbacks.KeyframeRequested != nil {
+		conn.callbacks.KeyframeRequested(ssrc, requestType)
+	}
+}
@@ -0,0 +1,85 @@
+// file: rtcp_test.go
+
+package connection
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+type keyframeRequest struct {
+	ssrc        uint32
+	requestType KeyframeRequestType
+}
+
+func TestKeyframeRequestsReachSender(t *testing.T) {
+	var mutex sync.Mutex
+	var requests []keyframeRequest
+	received := func() []keyframeRequest {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]keyframeRequest(nil), requests...)
+	}
+
+	sender := newTestPeer(t, "sender", webrtc.Configuration{}, WebRTCSettings{}, func(callbacks *WebRTCCallbacks) {
+		callbacks.KeyframeRequested = func(ssrc uint32, requestType KeyframeRequestType) {
+			mutex.Lock()
+			requests = append(requests, keyframeRequest{ssrc, requestType})
+			mutex.Unlock()
+		}
+	})
+	receiver := newTestPeer(t, "receiver", webrtc.Configuration{}, WebRTCSettings{})
+	connect(t, sender, receiver)
+	sendAudio(t, sender)
+
+	var ssrc uint32
+	waitFor(t, 10*time.Second, "inbound track", func() bool {
+		stats, err := receiver.conn.GetStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stats.InboundTracks) == 0 {
+			return false
+		}
+		ssrc = stats.InboundTracks[0].SSRC
+		return true
+	})
+
+	if err := receiver.conn.RequestKeyframe(ssrc+1, KeyframeRequestPLI); err == nil {
+		t.Fatal("keyframe requested for an unknown ssrc")
+	}
+
+	want := []keyframeRequest{
+		{ssrc, KeyframeRequestPLI},
+		{ssrc, KeyframeRequestFIR},
+		{ssrc, KeyframeRequestFIR},
+		{ssrc, KeyframeRequestPLI},
+	}
+	firs := 0
+	for i, request := range want {
+		if err := receiver.conn.RequestKeyframe(ssrc, request.requestType); err != nil {
+			t.Fatal(err)
+		}
+		if request.requestType == KeyframeRequestFIR {
+			firs++
+		}
+
+		// every FIR carries the next sequence number
+		receiver.conn.firMutex.Lock()
+		sequenceNumber := receiver.conn.firSequenceNumbers[ssrc]
+		receiver.conn.firMutex.Unlock()
+		if int(sequenceNumber) != firs {
+			t.Fatalf("after %d FIRs the next sequence number is %d", firs, sequenceNumber)
+		}
+
+		waitFor(t, 5*time.Second, request.requestType.String(), func() bool { return len(received()) > i })
+	}
+
+	if got := received(); !slices.Equal(got, want) {
+		t.Fatalf("keyframe requests %v, want %v", got, want)
+	}
+}
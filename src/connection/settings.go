@@ -0,0 +1,34 @@
+// file: settings.go
+
+package connection
+
+import "github.com/pion/webrtc/v4"
+
+// WebRTCSettings holds the pionc specific options of a connection that are
+// not part of webrtc.Configuration.
+type WebRTCSettings struct {
+	JitterBuffer        JitterBufferSettings
+	QualityMonitor      QualityMonitorSettings
+	Interceptors        InterceptorFlags
+	BandwidthEstimation BandwidthEstimationSettings
+	// AudioDirection is the direction of the default audio transceiver,
+	// RTPTransceiverDirectionUnknown selects sendrecv
+	AudioDirection webrtc.RTPTransceiverDirection
+	// PerfectNegotiation creates offers and answers automatically and
+	// resolves offer collisions, the host only forwards descriptions.
+	// Exactly one of the two peers must be Polite.
+	PerfectNegotiation bool
+	Polite             bool
+	// SimulcastLayer selects the layers of received simulcast tracks that
+	// are delivered, it can be changed per track with SelectSimulcastLayer
+	SimulcastLayer SimulcastLayerMode
+	Network        NetworkSettings
+	TURNREST       TURNRESTSettings
+	// UseTURNServer adds the embedded TURN server of StartTURNServer to the
+	// ICE servers
+	UseTURNServer bool
+	// CandidatesInDescription passes the local description with the
+	// gathered candidates to the LocalDescription callback, for hosts that
+	// don't trickle candidates through the IceCandidate callback
+	CandidatesInDescription bool
+}
@@ -0,0 +1,285 @@
+// file: simulcast.go
+
+package connection
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// AddSimulcastTransceiver adds a send only video transceiver with one
+// simulcast encoding per RID, e.g. "q", "h" and "f" for quarter, half and
+// full resolution. The host encodes every layer itself and writes its
+// frames with WriteSimulcastSample. The layers are announced with a=rid and
+// a=simulcast in the next offer.
+func (conn *WebRTCConnection) AddSimulcastTransceiver(rids []string) (*WebRTCTransceiver, error) {
+	if len(rids) < 2 {
+		return nil, fmt.Errorf("simulcast needs at least two layers, got %d", len(rids))
+	}
+
+	id := atomic.AddInt32(&conn.nextTransceiverId, 1)
+	trackID := "video-" + strconv.Itoa(int(id))
+	codec := supportedCodecs[webrtc.RTPCodecTypeVideo][0].RTPCodecCapability
+
+	layers := make([]*TrackLocalSample, 0, len(rids))
+	for _, rid := range rids {
+		rid = strings.TrimSpace(rid)
+		if rid == "" {
+			return nil, fmt.Errorf("empty simulcast rid")
+		}
+
+		track, err := newTrackLocalSample(codec, trackID, "stream", webrtc.WithRTPStreamID(rid))
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, track)
+	}
+
+	transceiver, err := conn.peerConnection.AddTransceiverFromTrack(layers[0], webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly})
+	if err != nil {
+		return nil, err
+	}
+
+	sender := transceiver.Sender()
+	for _, track := range layers[1:] {
+		if err := sender.AddEncoding(track); err != nil {
+			return nil, err
+		}
+	}
+	for _, track := range layers {
+		track.setMidSource(transceiver.Mid)
+	}
+
+	conn.waitGroup.Add(1)
+	go conn.senderRTCPReader(sender)
+	for _, track := range layers[1:] {
+		conn.waitGroup.Add(1)
+		go conn.simulcastRTCPReader(sender, track.RID())
+	}
+
+	newTransceiver := &WebRTCTransceiver{
+		Id:          id,
+		Transceiver: transceiver,
+		layers:      layers,
+	}
+	newTransceiver.track.Store(layers[0])
+	conn.transceivers = append(conn.transceivers, newTransceiver)
+
+	conn.callbacks.LogVerbose(fmt.Sprintf("added simulcast transceiver %d (%s)", id, strings.Join(rids, ",")))
+	return newTransceiver, nil
+}
+
+// WriteSimulcastSample sends an encoded video frame on the simulcast layer
+// with the given RID.
+func (conn *WebRTCConnection) WriteSimulcastSample(id int32, rid string, data []byte, duration time.Duration) error {
+	t, err := conn.transceiver(id)
+	if err != nil {
+		return err
+	}
+
+	for _, track := range t.layers {
+		if track.RID() == rid {
+			return track.WriteSample(media.Sample{Data: data, Duration: duration})
+		}
+	}
+
+	return fmt.Errorf("transceiver %d has no simulcast layer %s", id, rid)
+}
+
+// SimulcastLayerMode selects which layers of a received simulcast track are
+// delivered through the TrackPacket callback.
+type SimulcastLayerMode int
+
+const (
+	// SimulcastLayerAll delivers every layer, told apart by RTPPacketInfo.RID
+	SimulcastLayerAll SimulcastLayerMode = iota
+	// SimulcastLayerRID delivers the layer with the requested RID
+	SimulcastLayerRID
+	// SimulcastLayerHighest delivers the layer with the highest bitrate
+	SimulcastLayerHighest
+	// SimulcastLayerLowest delivers the active layer with the lowest bitrate
+	SimulcastLayerLowest
+)
+
+func (m SimulcastLayerMode) String() string {
+	switch m {
+	case SimulcastLayerAll:
+		return "all"
+	case SimulcastLayerRID:
+		return "rid"
+	case SimulcastLayerHighest:
+		return "highest"
+	case SimulcastLayerLowest:
+		return "lowest"
+	default:
+		return "unknown"
+	}
+}
+
+type simulcastReceiveLayer struct {
+	rid   string
+	ssrc  uint32
+	stats *ReceiveDataStats
+}
+
+// simulcastReceiver tracks the layers received on one transceiver (mid) and
+// the layer currently delivered.
+type simulcastReceiver struct {
+	mode     SimulcastLayerMode
+	rid      string
+	layers   []simulcastReceiveLayer
+	selected string
+}
+
+// SelectSimulcastLayer chooses the layers of the simulcast track received on
+// the transceiver with the given mid. rid is only used with
+// SimulcastLayerRID. When a different layer is selected the remote is asked
+// for a keyframe on it.
+func (conn *WebRTCConnection) SelectSimulcastLayer(mid string, mode SimulcastLayerMode, rid string) error {
+	if mode < SimulcastLayerAll || mode > SimulcastLayerLowest {
+		return fmt.Errorf("invalid simulcast layer mode %d", mode)
+	}
+	if mode == SimulcastLayerRID && rid == "" {
+		return fmt.Errorf("no rid given to select")
+	}
+
+	conn.simulcastMutex.Lock()
+	receiver, found := conn.simulcastReceivers[mid]
+	if !found {
+		conn.simulcastMutex.Unlock()
+		return fmt.Errorf("no simulcast track received on mid %s", mid)
+	}
+	receiver.mode = mode
+	receiver.rid = rid
+	conn.simulcastMutex.Unlock()
+
+	conn.callbacks.LogVerbose(fmt.Sprintf("simulcast layer of mid %s set to %s %s", mid, mode, rid))
+	conn.updateSimulcastSelection(mid)
+	return nil
+}
+
+// SelectedSimulcastLayer returns the RID of the layer delivered for the
+// simulcast track on the given mid, empty when all or no layers are delivered.
+func (conn *WebRTCConnection) SelectedSimulcastLayer(mid string) string {
+	conn.simulcastMutex.Lock()
+	defer conn.simulcastMutex.Unlock()
+
+	if receiver, found := conn.simulcastReceivers[mid]; found && receiver.mode != SimulcastLayerAll {
+		return receiver.selected
+	}
+
+	return ""
+}
+
+func (conn *WebRTCConnection) addSimulcastLayer(info RemoteTrackInfo, stats *ReceiveDataStats) {
+	conn.simulcastMutex.Lock()
+	if conn.simulcastReceivers == nil {
+		conn.simulcastReceivers = make(map[string]*simulcastReceiver)
+	}
+	receiver, found := conn.simulcastReceivers[info.Mid]
+	if !found {
+		receiver = &simulcastReceiver{mode: conn.settings.SimulcastLayer}
+		conn.simulcastReceivers[info.Mid] = receiver
+	}
+	receiver.layers = append(receiver.layers, simulcastReceiveLayer{rid: info.RID, ssrc: info.SSRC, stats: stats})
+	conn.simulcastMutex.Unlock()
+
+	conn.updateSimulcastSelection(info.Mid)
+}
+
+func (conn *WebRTCConnection) removeSimulcastLayer(info RemoteTrackInfo) {
+	conn.simulcastMutex.Lock()
+	receiver, found := conn.simulcastReceivers[info.Mid]
+	if !found {
+		conn.simulcastMutex.Unlock()
+		return
+	}
+	for i, layer := range receiver.layers {
+		if layer.ssrc == info.SSRC {
+			receiver.layers = append(receiver.layers[:i], receiver.layers[i+1:]...)
+			break
+		}
+	}
+	if len(receiver.layers) == 0 {
+		delete(conn.simulcastReceivers, info.Mid)
+	}
+	conn.simulcastMutex.Unlock()
+
+	conn.updateSimulcastSelection(info.Mid)
+}
+
+func (conn *WebRTCConnection) simulcastLayerSelected(mid, rid string) bool {
+	conn.simulcastMutex.Lock()
+	defer conn.simulcastMutex.Unlock()
+
+	receiver, found := conn.simulcastReceivers[mid]
+	if !found {
+		return false
+	}
+
+	return receiver.mode == SimulcastLayerAll || receiver.selected == rid
+}
+
+// updateSimulcastSelection picks the delivered layer of a simulcast track.
+// It runs when layers come and go, when the selection changes and once per
+// second with the new bitrates.
+func (conn *WebRTCConnection) updateSimulcastSelection(mid string) {
+	conn.simulcastMutex.Lock()
+	receiver, found := conn.simulcastReceivers[mid]
+	if !found || receiver.mode == SimulcastLayerAll {
+		conn.simulcastMutex.Unlock()
+		return
+	}
+
+	var selected *simulcastReceiveLayer
+	for i := range receiver.layers {
+		layer := &receiver.layers[i]
+		bitrate := layer.stats.Bitrate.Load()
+
+		switch receiver.mode {
+		case SimulcastLayerRID:
+			if layer.rid == receiver.rid {
+				selected = layer
+			}
+		case SimulcastLayerHighest:
+			if selected == nil || bitrate > selected.stats.Bitrate.Load() {
+				selected = layer
+			}
+		case SimulcastLayerLowest:
+			// layers the remote stopped sending have no bitrate
+			selectedBitrate := int64(0)
+			if selected != nil {
+				selectedBitrate = selected.stats.Bitrate.Load()
+			}
+			if selected == nil || (bitrate > 0 && (selectedBitrate == 0 || bitrate < selectedBitrate)) {
+				selected = layer
+			}
+		}
+	}
+
+	previous := receiver.selected
+	current := ""
+	ssrc := uint32(0)
+	if selected != nil {
+		current = selected.rid
+		ssrc = selected.ssrc
+	}
+	receiver.selected = current
+	conn.simulcastMutex.Unlock()
+
+	if current == previous || ssrc == 0 {
+		return
+	}
+
+	conn.callbacks.LogVerbose(fmt.Sprintf("simulcast layer of mid %s switched from %q to %q", mid, previous, current))
+	// the decoder needs a keyframe to start on the new layer
+	if err := conn.RequestKeyframe(ssrc, KeyframeRequestPLI); err != nil {
+		conn.callbacks.LogVerbose("Failed to request keyframe: " + err.Error())
+	}
+}
@@ -0,0 +1,172 @@
+// file: simulcast_test.go
+
+package connection
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+func TestSimulcastOfferAnnouncesLayers(t *testing.T) {
+	conn := newTestConnection(t, webrtc.Configuration{}, WebRTCSettings{}, testCallbacks(t))
+
+	if _, err := conn.AddSimulcastTransceiver([]string{"q"}); err == nil {
+		t.Fatal("simulcast transceiver with one layer added")
+	}
+	if _, err := conn.AddSimulcastTransceiver([]string{"q", " "}); err == nil {
+		t.Fatal("simulcast transceiver with an empty rid added")
+	}
+	if _, err := conn.AddSimulcastTransceiver([]string{"q", "h", "f"}); err != nil {
+		t.Fatal(err)
+	}
+
+	offer, err := conn.peerConnection.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	video := mediaSection(offer.SDP, "video")
+	for _, line := range []string{"a=sendonly", "a=rid:q send", "a=rid:h send", "a=rid:f send", "a=simulcast:send q;h;f"} {
+		if !strings.Contains(video, line+"\r\n") {
+			t.Errorf("offer has no %q:\n%s", line, video)
+		}
+	}
+}
+
+// simulcastPackets counts the packets of simulcast layers delivered through
+// TrackPacket by RID.
+type simulcastPackets struct {
+	mutex   sync.Mutex
+	mid     string
+	packets map[string]int
+}
+
+func (p *simulcastPackets) reset() map[string]int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	packets := p.packets
+	p.packets = make(map[string]int)
+	return packets
+}
+
+// delivered waits for the packets delivered over a second, packets passing
+// the layer selection while it changed are dropped first.
+func (p *simulcastPackets) delivered() map[string]int {
+	time.Sleep(200 * time.Millisecond)
+	p.reset()
+	time.Sleep(time.Second)
+	return p.reset()
+}
+
+func TestSimulcastLayerSelection(t *testing.T) {
+	var keyframeRequests atomic.Int32
+	sender := newTestPeer(t, "sender", webrtc.Configuration{}, WebRTCSettings{}, func(callbacks *WebRTCCallbacks) {
+		callbacks.KeyframeRequested = func(uint32, KeyframeRequestType) { keyframeRequests.Add(1) }
+	})
+
+	received := &simulcastPackets{packets: make(map[string]int)}
+	receiver := newTestPeer(t, "receiver", webrtc.Configuration{}, WebRTCSettings{}, func(callbacks *WebRTCCallbacks) {
+		callbacks.RemoteTrackAdded = func(info RemoteTrackInfo) {
+			if info.Kind == webrtc.RTPCodecTypeVideo {
+				received.mutex.Lock()
+				received.mid = info.Mid
+				received.mutex.Unlock()
+			}
+		}
+		callbacks.TrackPacket = func(info RTPPacketInfo, _ []byte) {
+			if info.RID == "" {
+				// the audio track
+				return
+			}
+			received.mutex.Lock()
+			received.packets[info.RID]++
+			received.mutex.Unlock()
+		}
+	})
+
+	transceiver, err := sender.conn.AddSimulcastTransceiver([]string{"q", "h", "f"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, sender, receiver)
+
+	// every layer is sent at its own bitrate
+	const frameDuration = time.Second / 30
+	frameSizes := map[string]int{"q": 100, "h": 400, "f": 1000}
+	sendPeriodically(t, frameDuration, func() {
+		for rid, size := range frameSizes {
+			sender.conn.WriteSimulcastSample(transceiver.Id, rid, make([]byte, size), frameDuration)
+		}
+	})
+
+	// all layers are delivered by default
+	waitFor(t, 10*time.Second, "all layers", func() bool {
+		received.mutex.Lock()
+		defer received.mutex.Unlock()
+		return received.packets["q"] > 0 && received.packets["h"] > 0 && received.packets["f"] > 0
+	})
+	received.mutex.Lock()
+	mid := received.mid
+	received.mutex.Unlock()
+	if layer := receiver.conn.SelectedSimulcastLayer(mid); layer != "" {
+		t.Errorf("layer %q selected while all are delivered", layer)
+	}
+
+	if err := receiver.conn.SelectSimulcastLayer("unknown", SimulcastLayerRID, "h"); err == nil {
+		t.Error("layer selected on an unknown mid")
+	}
+	if err := receiver.conn.SelectSimulcastLayer(mid, SimulcastLayerRID, ""); err == nil {
+		t.Error("layer selected without a rid")
+	}
+
+	for _, test := range []struct {
+		mode SimulcastLayerMode
+		rid  string
+		want string
+	}{
+		{SimulcastLayerRID, "h", "h"},
+		{SimulcastLayerRID, "q", "q"},
+		{SimulcastLayerHighest, "", "f"},
+		{SimulcastLayerLowest, "", "q"},
+	} {
+		requests := keyframeRequests.Load()
+		if err := receiver.conn.SelectSimulcastLayer(mid, test.mode, test.rid); err != nil {
+			t.Fatal(err)
+		}
+
+		// the bitrates are measured once per second
+		waitFor(t, 5*time.Second, test.mode.String()+" layer", func() bool {
+			return receiver.conn.SelectedSimulcastLayer(mid) == test.want
+		})
+		if delivered := received.delivered(); len(delivered) != 1 || delivered[test.want] == 0 {
+			t.Errorf("%s %s: delivered %v, want only %s", test.mode, test.rid, delivered, test.want)
+		}
+		// every step switches to another layer, which asks for a keyframe on it
+		waitFor(t, 5*time.Second, "keyframe request", func() bool { return keyframeRequests.Load() > requests })
+	}
+
+	stats, err := receiver.conn.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := make(map[string]InboundTrackStats)
+	for _, inbound := range stats.InboundTracks {
+		if inbound.Kind == webrtc.RTPCodecTypeVideo.String() {
+			layers[inbound.RID] = inbound
+		}
+	}
+	for rid := range frameSizes {
+		if inbound, found := layers[rid]; !found || inbound.Mid != mid || inbound.PacketsReceived == 0 {
+			t.Errorf("stats of layer %s: %+v", rid, inbound)
+		}
+	}
+	if len(layers) != len(frameSizes) {
+		t.Errorf("%d video layers in the stats", len(layers))
+	}
+}
@@ -0,0 +1,320 @@
+// file: stats.go
+
+package connection
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/stats"
+	"github.com/pion/webrtc/v4"
+)
+
+// ReceiveDataStats holds pionc's own counters for a remote track. It is
+// written by the track reader and may be read concurrently.
+type ReceiveDataStats struct {
+	TrackID   string
+	Kind      webrtc.RTPCodecType
+	ClockRate uint32
+	Mid       string
+	RID       string
+
+	NumPackets  atomic.Uint64
+	NumBytes    atomic.Uint64
+	PacketsLost atomic.Uint64
+	PacketRate  atomic.Int64
+	// payload bits per second, video tracks only
+	Bitrate atomic.Int64
+	// interarrival jitter in nanoseconds
+	Jitter atomic.Int64
+
+	jitterBuffer atomic.Pointer[JitterBuffer]
+
+	// only touched by the track reader
+	lastTransit time.Duration
+	haveTransit bool
+}
+
+// updateJitter updates the interarrival jitter estimate (RFC 3550, 6.4.1).
+func (s *ReceiveDataStats) updateJitter(timestamp uint32, arrival time.Time) {
+	if s.ClockRate == 0 {
+		return
+	}
+
+	transit := time.Duration(arrival.UnixNano()) - time.Duration(float64(timestamp)/float64(s.ClockRate)*float64(time.Second))
+	if s.haveTransit {
+		d := transit - s.lastTransit
+		if d < 0 {
+			d = -d
+		}
+		// a timestamp jump (DTX, wrap around) is not network jitter
+		if d < time.Second {
+			jitter := time.Duration(s.Jitter.Load())
+			s.Jitter.Store(int64(jitter + (d-jitter)/16))
+		}
+	}
+	s.lastTransit = transit
+	s.haveTransit = true
+}
+
+type InboundTrackStats struct {
+	SSRC            uint32  `json:"ssrc"`
+	TrackID         string  `json:"track_id"`
+	Kind            string  `json:"kind"`
+	Mid             string  `json:"mid,omitempty"`
+	RID             string  `json:"rid,omitempty"`
+	PacketsReceived uint64  `json:"packets_received"`
+	BytesReceived   uint64  `json:"bytes_received"`
+	PacketsLost     int64   `json:"packets_lost"`
+	Jitter          float64 `json:"jitter"`
+	NACKCount       uint32  `json:"nack_count"`
+	PLICount        uint32  `json:"pli_count"`
+	FIRCount        uint32  `json:"fir_count"`
+
+	// counters maintained by pionc itself
+	PacketRate       int64              `json:"packet_rate"`
+	Bitrate          int64              `json:"bitrate,omitempty"`
+	LocalPacketsLost uint64             `json:"local_packets_lost"`
+	JitterBuffer     *JitterBufferStats `json:"jitter_buffer,omitempty"`
+}
+
+type OutboundTrackStats struct {
+	SSRC          uint32  `json:"ssrc"`
+	TrackID       string  `json:"track_id"`
+	Kind          string  `json:"kind"`
+	RID           string  `json:"rid,omitempty"`
+	PacketsSent   uint64  `json:"packets_sent"`
+	BytesSent     uint64  `json:"bytes_sent"`
+	PacketsLost   int64   `json:"packets_lost"`
+	FractionLost  float64 `json:"fraction_lost"`
+	Jitter        float64 `json:"jitter"`
+	RoundTripTime float64 `json:"round_trip_time"`
+	NACKCount     uint32  `json:"nack_count"`
+	PLICount      uint32  `json:"pli_count"`
+	FIRCount      uint32  `json:"fir_count"`
+}
+
+type CandidateStats struct {
+	Address       string `json:"address"`
+	Port          int32  `json:"port"`
+	Protocol      string `json:"protocol"`
+	CandidateType string `json:"candidate_type"`
+}
+
+type CandidatePairStats struct {
+	Local         CandidateStats `json:"local"`
+	Remote        CandidateStats `json:"remote"`
+	RoundTripTime float64        `json:"round_trip_time"`
+	BytesSent     uint64         `json:"bytes_sent"`
+	BytesReceived uint64         `json:"bytes_received"`
+}
+
+type DataChannelMessageStats struct {
+	Label            string `json:"label"`
+	ID               int32  `json:"id"`
+	State            string `json:"state"`
+	MessagesSent     uint32 `json:"messages_sent"`
+	MessagesReceived uint32 `json:"messages_received"`
+	BytesSent        uint64 `json:"bytes_sent"`
+	BytesReceived    uint64 `json:"bytes_received"`
+}
+
+// ConnectionStats is the snapshot returned by GetStats. Durations (jitter,
+// round trip time) are expressed in seconds as in the W3C stats spec.
+type ConnectionStats struct {
+	Timestamp      int64                     `json:"timestamp"`
+	InboundTracks  []InboundTrackStats       `json:"inbound_tracks"`
+	OutboundTracks []OutboundTrackStats      `json:"outbound_tracks"`
+	CandidatePair  *CandidatePairStats       `json:"candidate_pair,omitempty"`
+	DataChannels   []DataChannelMessageStats `json:"data_channels"`
+	SendQueueDepth int                       `json:"send_queue_depth"`
+}
+
+// addReceiveStats registers the counters of a newly received remote track.
+func (conn *WebRTCConnection) addReceiveStats(info RemoteTrackInfo) *ReceiveDataStats {
+	s := &ReceiveDataStats{
+		TrackID:   info.ID,
+		Kind:      info.Kind,
+		ClockRate: info.ClockRate,
+		Mid:       info.Mid,
+		RID:       info.RID,
+	}
+
+	conn.statsMutex.Lock()
+	defer conn.statsMutex.Unlock()
+
+	if conn.receiveStats == nil {
+		conn.receiveStats = make(map[uint32]*ReceiveDataStats)
+	}
+	conn.receiveStats[info.SSRC] = s
+
+	return s
+}
+
+// GetStats combines PeerConnection.GetStats with the RTP stream statistics
+// of the stats interceptor and pionc's own counters.
+// ErrConnectionClosed is returned after Close.
+func (conn *WebRTCConnection) GetStats() (ConnectionStats, error) {
+	peerConnection, err := conn.lockPeerConnection()
+	if err != nil {
+		return ConnectionStats{}, err
+	}
+	defer conn.peerConnectionMutex.RUnlock()
+
+	result := ConnectionStats{
+		Timestamp:      time.Now().UnixMilli(),
+		InboundTracks:  []InboundTrackStats{},
+		OutboundTracks: []OutboundTrackStats{},
+		DataChannels:   []DataChannelMessageStats{},
+		SendQueueDepth: int(conn.sendQueueDepth.Load()),
+	}
+	if conn.localTrackChannel != nil {
+		result.SendQueueDepth += len(conn.localTrackChannel)
+	}
+
+	conn.statsMutex.Lock()
+	for ssrc, s := range conn.receiveStats {
+		inbound := InboundTrackStats{
+			SSRC:             ssrc,
+			TrackID:          s.TrackID,
+			Kind:             s.Kind.String(),
+			Mid:              s.Mid,
+			RID:              s.RID,
+			PacketsReceived:  s.NumPackets.Load(),
+			BytesReceived:    s.NumBytes.Load(),
+			PacketRate:       s.PacketRate.Load(),
+			Bitrate:          s.Bitrate.Load(),
+			LocalPacketsLost: s.PacketsLost.Load(),
+			Jitter:           time.Duration(s.Jitter.Load()).Seconds(),
+		}
+
+		if rtpStats := conn.rtpStreamStats(ssrc); rtpStats != nil {
+			inbound.PacketsReceived = rtpStats.InboundRTPStreamStats.PacketsReceived
+			inbound.BytesReceived = rtpStats.InboundRTPStreamStats.BytesReceived
+			inbound.PacketsLost = rtpStats.InboundRTPStreamStats.PacketsLost
+			inbound.NACKCount = rtpStats.InboundRTPStreamStats.NACKCount
+			inbound.PLICount = rtpStats.InboundRTPStreamStats.PLICount
+			inbound.FIRCount = rtpStats.InboundRTPStreamStats.FIRCount
+		}
+
+		if jb := s.jitterBuffer.Load(); jb != nil {
+			jbStats := jb.Stats()
+			inbound.JitterBuffer = &jbStats
+		}
+
+		result.InboundTracks = append(result.InboundTracks, inbound)
+	}
+	conn.statsMutex.Unlock()
+
+	for _, sender := range peerConnection.GetSenders() {
+		track := sender.Track()
+		if track == nil {
+			continue
+		}
+
+		for _, encoding := range sender.GetParameters().Encodings {
+			outbound := OutboundTrackStats{
+				SSRC:    uint32(encoding.SSRC),
+				TrackID: track.ID(),
+				Kind:    track.Kind().String(),
+				RID:     encoding.RID,
+			}
+
+			if rtpStats := conn.rtpStreamStats(outbound.SSRC); rtpStats != nil {
+				outbound.PacketsSent = rtpStats.OutboundRTPStreamStats.PacketsSent
+				outbound.BytesSent = rtpStats.OutboundRTPStreamStats.BytesSent
+				outbound.NACKCount = rtpStats.OutboundRTPStreamStats.NACKCount
+				outbound.PLICount = rtpStats.OutboundRTPStreamStats.PLICount
+				outbound.FIRCount = rtpStats.OutboundRTPStreamStats.FIRCount
+				outbound.PacketsLost = rtpStats.RemoteInboundRTPStreamStats.PacketsLost
+				outbound.FractionLost = rtpStats.RemoteInboundRTPStreamStats.FractionLost
+				outbound.Jitter = rtpStats.RemoteInboundRTPStreamStats.Jitter
+				outbound.RoundTripTime = rtpStats.RemoteInboundRTPStreamStats.RoundTripTime.Seconds()
+			}
+
+			result.OutboundTracks = append(result.OutboundTracks, outbound)
+		}
+	}
+
+	report := peerConnection.GetStats()
+	for _, v := range report {
+		switch s := v.(type) {
+		case webrtc.ICECandidatePairStats:
+			if !s.Nominated || s.State != webrtc.StatsICECandidatePairStateSucceeded {
+				continue
+			}
+			result.CandidatePair = &CandidatePairStats{
+				Local:         candidateStats(report, s.LocalCandidateID),
+				Remote:        candidateStats(report, s.RemoteCandidateID),
+				RoundTripTime: s.CurrentRoundTripTime,
+				BytesSent:     s.BytesSent,
+				BytesReceived: s.BytesReceived,
+			}
+		case webrtc.DataChannelStats:
+			result.DataChannels = append(result.DataChannels, DataChannelMessageStats{
+				Label:            s.Label,
+				ID:               s.DataChannelIdentifier,
+				State:            s.State.String(),
+				MessagesSent:     s.MessagesSent,
+				MessagesReceived: s.MessagesReceived,
+				BytesSent:        s.BytesSent,
+				BytesReceived:    s.BytesReceived,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// GetStatsJSON returns the GetStats snapshot serialized as JSON.
+func (conn *WebRTCConnection) GetStatsJSON() (string, error) {
+	connectionStats, err := conn.GetStats()
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(connectionStats)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// registerStatsInterceptor installs the stats interceptor, which provides
+// the per SSRC RTP stream statistics of GetStats. onGetter is called with
+// the getter of each new peer connection.
+func registerStatsInterceptor(registry *interceptor.Registry, onGetter func(stats.Getter)) error {
+	statsInterceptorFactory, err := stats.NewInterceptor()
+	if err != nil {
+		return err
+	}
+
+	statsInterceptorFactory.OnNewPeerConnection(func(_ string, g stats.Getter) {
+		onGetter(g)
+	})
+	registry.Add(statsInterceptorFactory)
+
+	return nil
+}
+
+func (conn *WebRTCConnection) rtpStreamStats(ssrc uint32) *stats.Stats {
+	getter := conn.statsGetter.Load()
+	if getter == nil {
+		return nil
+	}
+	return (*getter).Get(ssrc)
+}
+
+func candidateStats(report webrtc.StatsReport, id string) CandidateStats {
+	switch s := report[id].(type) {
+	case webrtc.ICECandidateStats:
+		return CandidateStats{
+			Address:       s.IP,
+			Port:          s.Port,
+			Protocol:      s.Protocol,
+			CandidateType: s.CandidateType.String(),
+		}
+	}
+	return CandidateStats{}
+}
@@ -0,0 +1,120 @@
+// file: stats_test.go
+
+package connection
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+func TestGetStatsAfterClose(t *testing.T) {
+	conn := newTestConnection(t, webrtc.Configuration{}, WebRTCSettings{}, testCallbacks(t))
+
+	if _, err := conn.GetStatsJSON(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.GetStats(); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("GetStats after Close returned %v", err)
+	}
+	if _, err := conn.GetStatsJSON(); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("GetStatsJSON after Close returned %v", err)
+	}
+}
+
+func TestGetStatsDuringClose(t *testing.T) {
+	conn := newTestConnection(t, webrtc.Configuration{}, WebRTCSettings{}, testCallbacks(t))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, err := conn.GetStats(); errors.Is(err, ErrConnectionClosed) {
+				return
+			}
+			if _, err := conn.Certificate(); errors.Is(err, ErrConnectionClosed) {
+				return
+			}
+		}
+	}()
+
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+}
+
+func TestGetStatsJSONOfConnectedPeers(t *testing.T) {
+	const messages = 5
+
+	sender := newTestPeer(t, "sender", webrtc.Configuration{}, WebRTCSettings{})
+	receiver := newTestPeer(t, "receiver", webrtc.Configuration{}, WebRTCSettings{})
+	channel, err := sender.conn.CreateDataChannel("stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, sender, receiver)
+	sendAudio(t, sender)
+
+	waitFor(t, 10*time.Second, "open data channel", func() bool {
+		return sender.conn.GetDataChannelReadyState(channel.Id) == webrtc.DataChannelStateOpen
+	})
+	for i := 0; i < messages; i++ {
+		if err := sender.conn.SendDataChannelText(channel.Id, "hello"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	statsOf := func(p *testPeer) ConnectionStats {
+		data, err := p.conn.GetStatsJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s ConnectionStats
+		if err := json.Unmarshal([]byte(data), &s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	dataChannel := func(s ConnectionStats) DataChannelMessageStats {
+		for _, d := range s.DataChannels {
+			if d.Label == "stats" {
+				return d
+			}
+		}
+		return DataChannelMessageStats{}
+	}
+
+	var sent, received ConnectionStats
+	waitFor(t, 10*time.Second, "stats", func() bool {
+		sent, received = statsOf(sender), statsOf(receiver)
+		return len(received.InboundTracks) > 0 && received.InboundTracks[0].PacketsReceived >= 10 &&
+			len(sent.OutboundTracks) > 0 && sent.OutboundTracks[0].PacketsSent >= 10 &&
+			sent.CandidatePair != nil && received.CandidatePair != nil &&
+			dataChannel(received).MessagesReceived == messages
+	})
+
+	inbound := received.InboundTracks[0]
+	if inbound.Kind != "audio" || inbound.BytesReceived == 0 {
+		t.Errorf("inbound track %+v", inbound)
+	}
+	if outbound := sent.OutboundTracks[0]; outbound.BytesSent == 0 {
+		t.Errorf("outbound track %+v", outbound)
+	}
+	if pair := sent.CandidatePair; pair.Local.Address == "" || pair.Remote.Port == 0 || pair.BytesSent == 0 || pair.BytesReceived == 0 {
+		t.Errorf("candidate pair %+v", pair)
+	}
+	if d := dataChannel(sent); d.MessagesSent != messages || d.BytesSent != messages*uint64(len("hello")) || d.State != "open" {
+		t.Errorf("sent data channel %+v", d)
+	}
+	if d := dataChannel(received); d.BytesReceived != messages*uint64(len("hello")) {
+		t.Errorf("received data channel %+v", d)
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/pion/rtp"
 	"github.com/pion/rtp/codecs"
+	"github.com/pion/sdp/v3"
 	"github.com/pion/webrtc/v4"
 	"github.com/pion/webrtc/v4/pkg/media"
 )
@@ -21,6 +22,12 @@ type TrackLocalSample struct {
 	rtpTrack   *webrtc.TrackLocalStaticRTP
 	clockRate  float64
 	mu         sync.RWMutex
+
+	// simulcast layers identify themselves with the mid and rid header
+	// extensions, there are no a=ssrc lines for them
+	midSource      func() string
+	midExtensionID uint8
+	ridExtensionID uint8
 }
 
 // ID is the unique identifier for this Track. This should be unique for the
@@ -53,6 +60,17 @@ func (s *TrackLocalSample) Bind(t webrtc.TrackLocalContext) (webrtc.RTPCodecPara
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.RID() != "" {
+		for _, ext := range t.HeaderExtensions() {
+			switch ext.URI {
+			case sdp.SDESMidURI:
+				s.midExtensionID = uint8(ext.ID)
+			case sdp.SDESRTPStreamIDURI:
+				s.ridExtensionID = uint8(ext.ID)
+			}
+		}
+	}
+
 	// We only need one packetizer
 	if s.packetizer != nil {
 		return codec, nil
@@ -74,12 +92,50 @@ func (s *TrackLocalSample) Bind(t webrtc.TrackLocalContext) (webrtc.RTPCodecPara
 	)
 
 	// Most of the reason to implement this TrackLocalSample class is so I can add these absolute time stamps out.
-	s.packetizer.EnableAbsSendTime(1)
+	// Only write them when the extension was negotiated, with the id the remote agreed to.
+	for _, ext := range t.HeaderExtensions() {
+		if ext.URI == sdp.ABSSendTimeURI {
+			s.packetizer.EnableAbsSendTime(ext.ID)
+			break
+		}
+	}
 
 	s.clockRate = float64(codec.RTPCodecCapability.ClockRate)
 	return codec, nil
 }
 
+// continueFrom lets the track continue the RTP sequence numbers and
+// timestamps of prev when it replaces prev on a sender. Receivers (and SRTP
+// replay protection) would drop packets after a jump back.
+func (s *TrackLocalSample) continueFrom(prev *TrackLocalSample) {
+	if prev == s || !strings.EqualFold(prev.Codec().MimeType, s.Codec().MimeType) {
+		return
+	}
+
+	prev.mu.RLock()
+	defer prev.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if prev.packetizer == nil {
+		return
+	}
+
+	// the packetizer of prev is shared, prev must not be written anymore
+	s.packetizer = prev.packetizer
+	s.sequencer = prev.sequencer
+	s.clockRate = prev.clockRate
+}
+
+// setMidSource sets where the track gets the mid of its transceiver from,
+// the mid is only known once the transceiver has been negotiated.
+func (s *TrackLocalSample) setMidSource(midSource func() string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.midSource = midSource
+}
+
 // Unbind implements the teardown logic when the track is no longer needed. This happens
 // because a track has been stopped.
 func (s *TrackLocalSample) Unbind(t webrtc.TrackLocalContext) error {
@@ -94,6 +150,9 @@ func (s *TrackLocalSample) WriteSample(sample media.Sample) error {
 	s.mu.RLock()
 	p := s.packetizer
 	clockRate := s.clockRate
+	midSource := s.midSource
+	midExtensionID := s.midExtensionID
+	ridExtensionID := s.ridExtensionID
 	s.mu.RUnlock()
 
 	if p == nil {
@@ -111,8 +170,23 @@ func (s *TrackLocalSample) WriteSample(sample media.Sample) error {
 	}
 	packets := p.Packetize(sample.Data, samples)
 
+	mid := ""
+	if midSource != nil {
+		mid = midSource()
+	}
+
 	writeErrs := []error{}
 	for _, p := range packets {
+		if midExtensionID != 0 && mid != "" {
+			if err := p.Header.SetExtension(midExtensionID, []byte(mid)); err != nil {
+				writeErrs = append(writeErrs, err)
+			}
+		}
+		if ridExtensionID != 0 {
+			if err := p.Header.SetExtension(ridExtensionID, []byte(s.RID())); err != nil {
+				writeErrs = append(writeErrs, err)
+			}
+		}
 		if err := s.rtpTrack.WriteRTP(p); err != nil {
 			writeErrs = append(writeErrs, err)
 		}
@@ -0,0 +1,359 @@
+// file: transceivers.go
+
+package connection
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// WebRTCTransceiver is a transceiver created by pionc. Id is the handle used
+// by the C API, the transceiver of the default audio track has id 1.
+type WebRTCTransceiver struct {
+	Id          int32
+	Transceiver *webrtc.RTPTransceiver
+
+	// track is sent while the direction includes sending and the
+	// transceiver is not paused
+	track  atomic.Pointer[TrackLocalSample]
+	paused atomic.Bool
+
+	// layers are the simulcast encodings, track is the first of them
+	layers []*TrackLocalSample
+
+	// transport is the DTLS transport of the transceiver, a sender created
+	// when sending is enabled again uses it
+	transport *webrtc.DTLSTransport
+}
+
+func newTrackLocalSample(c webrtc.RTPCodecCapability, id, streamID string, options ...func(*webrtc.TrackLocalStaticRTP)) (*TrackLocalSample, error) {
+	rtpTrack, err := webrtc.NewTrackLocalStaticRTP(c, id, streamID, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TrackLocalSample{rtpTrack: rtpTrack}, nil
+}
+
+func directionSends(direction webrtc.RTPTransceiverDirection) bool {
+	return direction == webrtc.RTPTransceiverDirectionSendrecv || direction == webrtc.RTPTransceiverDirectionSendonly
+}
+
+func directionReceives(direction webrtc.RTPTransceiverDirection) bool {
+	return direction == webrtc.RTPTransceiverDirectionSendrecv || direction == webrtc.RTPTransceiverDirectionRecvonly
+}
+
+// AddTransceiver adds a transceiver of the given kind. Transceivers that send
+// get their own local track, written with WriteTransceiverSample. The new
+// transceiver is only announced to the remote after a new offer.
+func (conn *WebRTCConnection) AddTransceiver(kind webrtc.RTPCodecType, direction webrtc.RTPTransceiverDirection) (*WebRTCTransceiver, error) {
+	codecs := supportedCodecs[kind]
+	if len(codecs) == 0 {
+		return nil, fmt.Errorf("cannot add transceiver of kind %s", kind)
+	}
+
+	id := atomic.AddInt32(&conn.nextTransceiverId, 1)
+	track, err := newTrackLocalSample(codecs[0].RTPCodecCapability, kind.String()+"-"+strconv.Itoa(int(id)), "stream")
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.addTransceiver(id, kind, direction, track)
+}
+
+func (conn *WebRTCConnection) addTransceiver(id int32, kind webrtc.RTPCodecType, direction webrtc.RTPTransceiverDirection, track *TrackLocalSample) (*WebRTCTransceiver, error) {
+	if direction == webrtc.RTPTransceiverDirectionUnknown {
+		direction = webrtc.RTPTransceiverDirectionSendrecv
+	}
+
+	var transceiver *webrtc.RTPTransceiver
+	var transport *webrtc.DTLSTransport
+	var err error
+	switch direction {
+	case webrtc.RTPTransceiverDirectionSendrecv, webrtc.RTPTransceiverDirectionSendonly:
+		transceiver, err = conn.peerConnection.AddTransceiverFromTrack(track, webrtc.RTPTransceiverInit{Direction: direction})
+		if err == nil {
+			transport = transceiver.Sender().Transport()
+			conn.waitGroup.Add(1)
+			go conn.senderRTCPReader(transceiver.Sender())
+		}
+	case webrtc.RTPTransceiverDirectionRecvonly:
+		transceiver, err = conn.peerConnection.AddTransceiverFromKind(kind, webrtc.RTPTransceiverInit{Direction: direction})
+		if err == nil {
+			transport = transceiver.Receiver().Transport()
+		}
+	case webrtc.RTPTransceiverDirectionInactive:
+		// pion only creates inactive transceivers by removing the track of a sendonly one
+		transceiver, err = conn.peerConnection.AddTransceiverFromTrack(track, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly})
+		if err == nil {
+			transport = transceiver.Sender().Transport()
+			err = conn.peerConnection.RemoveTrack(transceiver.Sender())
+		}
+	default:
+		err = fmt.Errorf("invalid transceiver direction %d", direction)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	newTransceiver := &WebRTCTransceiver{
+		Id:          id,
+		Transceiver: transceiver,
+		transport:   transport,
+	}
+	newTransceiver.track.Store(track)
+	conn.transceivers = append(conn.transceivers, newTransceiver)
+
+	conn.callbacks.LogVerbose(fmt.Sprintf("added %s transceiver %d (%s)", kind, id, direction))
+	return newTransceiver, nil
+}
+
+func (conn *WebRTCConnection) transceiver(id int32) (*WebRTCTransceiver, error) {
+	for _, v := range conn.transceivers {
+		if v.Id == id {
+			return v, nil
+		}
+	}
+
+	return nil, fmt.Errorf("transceiver %d not found", id)
+}
+
+// GetTransceiverDirection returns the preferred direction of a transceiver.
+func (conn *WebRTCConnection) GetTransceiverDirection(id int32) webrtc.RTPTransceiverDirection {
+	t, err := conn.transceiver(id)
+	if err != nil {
+		return webrtc.RTPTransceiverDirectionUnknown
+	}
+
+	return t.Transceiver.Direction()
+}
+
+// SetTransceiverDirection starts or stops sending on a transceiver, the
+// change takes effect with the next offer/answer. pion cannot change whether
+// an existing transceiver receives, so only the sending half may change:
+// sendrecv <-> recvonly and sendonly <-> inactive.
+func (conn *WebRTCConnection) SetTransceiverDirection(id int32, direction webrtc.RTPTransceiverDirection) error {
+	t, err := conn.transceiver(id)
+	if err != nil {
+		return err
+	}
+
+	current := t.Transceiver.Direction()
+	if direction == current {
+		return nil
+	}
+	if directionReceives(direction) != directionReceives(current) {
+		return fmt.Errorf("cannot change direction of transceiver %d from %s to %s", id, current, direction)
+	}
+	if len(t.layers) > 0 {
+		return fmt.Errorf("cannot change direction of simulcast transceiver %d", id)
+	}
+
+	if directionSends(direction) {
+		track := t.track.Load()
+		// the sender of the transceiver was stopped when sending was disabled
+		sender, err := conn.api.NewRTPSender(track, t.transport)
+		if err != nil {
+			return err
+		}
+		if err := t.Transceiver.SetSender(sender, track); err != nil {
+			return err
+		}
+		t.paused.Store(false)
+
+		conn.waitGroup.Add(1)
+		go conn.senderRTCPReader(sender)
+
+		// pion only signals negotiation needed when a track is removed
+		conn.negotiationNeeded()
+	} else if err := conn.peerConnection.RemoveTrack(t.Transceiver.Sender()); err != nil {
+		return err
+	}
+
+	conn.callbacks.LogVerbose(fmt.Sprintf("transceiver %d direction changed from %s to %s", id, current, t.Transceiver.Direction()))
+	return nil
+}
+
+// RemoveTrack stops sending the local track of a transceiver. The transceiver
+// stays in place with a direction of recvonly or inactive, sending can be
+// enabled again with SetTransceiverDirection.
+func (conn *WebRTCConnection) RemoveTrack(id int32) error {
+	t, err := conn.transceiver(id)
+	if err != nil {
+		return err
+	}
+
+	sender := t.Transceiver.Sender()
+	if sender == nil {
+		return fmt.Errorf("transceiver %d has no track", id)
+	}
+
+	if err := conn.peerConnection.RemoveTrack(sender); err != nil {
+		return err
+	}
+
+	conn.callbacks.LogVerbose(fmt.Sprintf("removed track of transceiver %d", id))
+	return nil
+}
+
+// SetTransceiverCodecPreferences restricts and orders the codecs negotiated
+// for a transceiver. An empty list restores the default codecs. The local
+// track of the transceiver is switched to the first preferred codec.
+func (conn *WebRTCConnection) SetTransceiverCodecPreferences(id int32, mimeTypes []string) error {
+	t, err := conn.transceiver(id)
+	if err != nil {
+		return err
+	}
+
+	codecs, err := codecsByMimeType(t.Transceiver.Kind(), mimeTypes)
+	if err != nil {
+		return err
+	}
+	if err := t.Transceiver.SetCodecPreferences(codecs); err != nil {
+		return err
+	}
+
+	if len(codecs) == 0 {
+		codecs = supportedCodecs[t.Transceiver.Kind()]
+	}
+	current := t.track.Load()
+	if current.Codec().MimeType == codecs[0].MimeType {
+		return nil
+	}
+
+	// the track is bound to its codec, so a new track replaces the old one
+	track, err := newTrackLocalSample(codecs[0].RTPCodecCapability, current.ID(), current.StreamID())
+	if err != nil {
+		return err
+	}
+	if sender := t.Transceiver.Sender(); sender != nil && !t.paused.Load() {
+		if err := sender.ReplaceTrack(track); err != nil {
+			return err
+		}
+	}
+	t.track.Store(track)
+
+	return nil
+}
+
+// ReplaceTrack switches the local track of a transceiver without a new
+// offer/answer, e.g. to change the audio source mid-call. The new track must
+// use the negotiated codec. A nil track stops transmission like PauseTrack.
+func (conn *WebRTCConnection) ReplaceTrack(id int32, track *TrackLocalSample) error {
+	if track == nil {
+		return conn.PauseTrack(id)
+	}
+
+	t, sender, err := conn.transceiverSender(id)
+	if err != nil {
+		return err
+	}
+	if track.Kind() != t.Transceiver.Kind() {
+		return fmt.Errorf("cannot replace the %s track of transceiver %d with a %s track", t.Transceiver.Kind(), id, track.Kind())
+	}
+
+	track.continueFrom(t.track.Load())
+	if err := sender.ReplaceTrack(track); err != nil {
+		return err
+	}
+	t.track.Store(track)
+	t.paused.Store(false)
+
+	conn.callbacks.LogVerbose(fmt.Sprintf("replaced track of transceiver %d with %s", id, track.ID()))
+	return nil
+}
+
+// NewTransceiverTrack creates a local track for ReplaceTrack that uses the
+// codec and stream of the current track of a transceiver.
+func (conn *WebRTCConnection) NewTransceiverTrack(id int32, trackID string) (*TrackLocalSample, error) {
+	t, err := conn.transceiver(id)
+	if err != nil {
+		return nil, err
+	}
+
+	current := t.track.Load()
+	return newTrackLocalSample(current.Codec(), trackID, current.StreamID())
+}
+
+// PauseTrack stops sending the local track of a transceiver without a new
+// offer/answer. Samples written while paused are dropped.
+func (conn *WebRTCConnection) PauseTrack(id int32) error {
+	t, sender, err := conn.transceiverSender(id)
+	if err != nil {
+		return err
+	}
+	if t.paused.Load() {
+		return nil
+	}
+
+	if err := sender.ReplaceTrack(nil); err != nil {
+		return err
+	}
+	t.paused.Store(true)
+
+	conn.callbacks.LogVerbose(fmt.Sprintf("paused track of transceiver %d", id))
+	return nil
+}
+
+// ResumeTrack sends the local track of a paused transceiver again.
+func (conn *WebRTCConnection) ResumeTrack(id int32) error {
+	t, sender, err := conn.transceiverSender(id)
+	if err != nil {
+		return err
+	}
+	if !t.paused.Load() {
+		return nil
+	}
+
+	if err := sender.ReplaceTrack(t.track.Load()); err != nil {
+		return err
+	}
+	t.paused.Store(false)
+
+	conn.callbacks.LogVerbose(fmt.Sprintf("resumed track of transceiver %d", id))
+	return nil
+}
+
+// IsTrackPaused reports whether the local track of a transceiver is paused.
+func (conn *WebRTCConnection) IsTrackPaused(id int32) bool {
+	t, err := conn.transceiver(id)
+	if err != nil {
+		return false
+	}
+
+	return t.paused.Load()
+}
+
+func (conn *WebRTCConnection) transceiverSender(id int32) (*WebRTCTransceiver, *webrtc.RTPSender, error) {
+	t, err := conn.transceiver(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sender := t.Transceiver.Sender()
+	if sender == nil {
+		return nil, nil, fmt.Errorf("transceiver %d does not send", id)
+	}
+	if len(t.layers) > 0 {
+		// pion cannot bind a new track to the simulcast encodings
+		return nil, nil, fmt.Errorf("cannot replace the tracks of simulcast transceiver %d", id)
+	}
+
+	return t, sender, nil
+}
+
+// WriteTransceiverSample sends a media sample (an encoded audio or video
+// frame) on the local track of a transceiver.
+func (conn *WebRTCConnection) WriteTransceiverSample(id int32, data []byte, duration time.Duration) error {
+	t, err := conn.transceiver(id)
+	if err != nil {
+		return err
+	}
+
+	return t.track.Load().WriteSample(media.Sample{Data: data, Duration: duration})
+}
@@ -0,0 +1,276 @@
+// file: transceivers_test.go
+
+package connection
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// negotiatedDirection returns the direction of the first local section of
+// kind, or "" while a negotiation is in flight.
+func (p *testPeer) negotiatedDirection(kind string) string {
+	if !p.settled("m=" + kind) {
+		return ""
+	}
+
+	section := mediaSection(p.conn.peerConnection.CurrentLocalDescription().SDP, kind)
+	for _, direction := range []string{"sendrecv", "sendonly", "recvonly", "inactive"} {
+		if strings.Contains(section, "a="+direction+"\r\n") {
+			return direction
+		}
+	}
+	return ""
+}
+
+// inboundPackets returns the packets the peer received on all remote tracks
+// of kind.
+func inboundPackets(t *testing.T, p *testPeer, kind webrtc.RTPCodecType) uint64 {
+	stats, err := p.conn.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var packets uint64
+	for _, track := range stats.InboundTracks {
+		if track.Kind == kind.String() {
+			packets += track.PacketsReceived
+		}
+	}
+	return packets
+}
+
+// waitPackets waits until the peer received more packets of kind.
+func waitPackets(t *testing.T, p *testPeer, kind webrtc.RTPCodecType) {
+	t.Helper()
+
+	before := inboundPackets(t, p, kind)
+	waitFor(t, 10*time.Second, p.name+" receiving "+kind.String(), func() bool {
+		return inboundPackets(t, p, kind) >= before+10
+	})
+}
+
+// checkNoPackets fails if the peer keeps receiving packets of kind.
+func checkNoPackets(t *testing.T, p *testPeer, kind webrtc.RTPCodecType) {
+	t.Helper()
+
+	// packets in flight may still arrive
+	time.Sleep(200 * time.Millisecond)
+	before := inboundPackets(t, p, kind)
+	time.Sleep(time.Second)
+	if after := inboundPackets(t, p, kind); after != before {
+		t.Fatalf("%s received %d %s packets", p.name, after-before, kind)
+	}
+}
+
+// newNegotiatingPeers returns two connected perfect negotiation peers.
+func newNegotiatingPeers(t *testing.T) (*testPeer, *testPeer) {
+	t.Helper()
+
+	impolite := newTestPeer(t, "impolite", webrtc.Configuration{}, WebRTCSettings{PerfectNegotiation: true})
+	polite := newTestPeer(t, "polite", webrtc.Configuration{}, WebRTCSettings{PerfectNegotiation: true, Polite: true})
+	newTestSignaling(t, impolite, polite)
+	waitConnected(t, impolite, polite)
+
+	return impolite, polite
+}
+
+func TestSetTransceiverDirectionStopsAndRestartsSending(t *testing.T) {
+	sender, receiver := newNegotiatingPeers(t)
+
+	transceiver, err := sender.conn.AddTransceiver(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverDirectionSendrecv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendVideo(t, sender, transceiver.Id)
+	waitFor(t, 10*time.Second, "video negotiated", func() bool {
+		return sender.negotiatedDirection("video") == "sendrecv"
+	})
+	waitPackets(t, receiver, webrtc.RTPCodecTypeVideo)
+
+	if err := sender.conn.SetTransceiverDirection(transceiver.Id, webrtc.RTPTransceiverDirectionRecvonly); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 10*time.Second, "recvonly negotiated", func() bool {
+		return sender.negotiatedDirection("video") == "recvonly" && receiver.negotiatedDirection("video") == "inactive"
+	})
+	checkNoPackets(t, receiver, webrtc.RTPCodecTypeVideo)
+
+	if err := sender.conn.SetTransceiverDirection(transceiver.Id, webrtc.RTPTransceiverDirectionSendrecv); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 10*time.Second, "sendrecv negotiated", func() bool {
+		return sender.negotiatedDirection("video") == "sendrecv" && receiver.negotiatedDirection("video") == "recvonly"
+	})
+	waitPackets(t, receiver, webrtc.RTPCodecTypeVideo)
+}
+
+func TestTransceiverDirectionAndCodecPreferencesNegotiated(t *testing.T) {
+	var mutex sync.Mutex
+	var tracks []RemoteTrackInfo
+	sender := newTestPeer(t, "sender", webrtc.Configuration{}, WebRTCSettings{})
+	receiver := newTestPeer(t, "receiver", webrtc.Configuration{}, WebRTCSettings{}, func(callbacks *WebRTCCallbacks) {
+		callbacks.RemoteTrackAdded = func(info RemoteTrackInfo) {
+			mutex.Lock()
+			tracks = append(tracks, info)
+			mutex.Unlock()
+		}
+	})
+
+	transceiver, err := sender.conn.AddTransceiver(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverDirectionSendonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.conn.SetTransceiverCodecPreferences(transceiver.Id, []string{"video/H264", "video/VP9"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.conn.SetTransceiverCodecPreferences(transceiver.Id, []string{"video/AV1"}); err == nil {
+		t.Fatal("unsupported codec preferred")
+	}
+	connect(t, sender, receiver)
+	sendVideo(t, sender, transceiver.Id)
+
+	waitFor(t, 10*time.Second, "video negotiated", func() bool {
+		return sender.negotiatedDirection("video") == "sendonly" && receiver.negotiatedDirection("video") == "recvonly"
+	})
+	if got := sender.conn.GetTransceiverDirection(transceiver.Id); got != webrtc.RTPTransceiverDirectionSendonly {
+		t.Errorf("transceiver direction %s", got)
+	}
+
+	for _, description := range []*webrtc.SessionDescription{
+		sender.conn.peerConnection.CurrentLocalDescription(),
+		receiver.conn.peerConnection.CurrentLocalDescription(),
+	} {
+		video := mediaSection(description.SDP, "video")
+		if !strings.HasPrefix(video, "video 9 UDP/TLS/RTP/SAVPF 102 98") {
+			t.Errorf("%s video payload types: %s", description.Type, video[:strings.Index(video, "\r\n")])
+		}
+		if !strings.Contains(video, "a=rtpmap:102 H264/90000") || strings.Contains(video, "VP8/90000") {
+			t.Errorf("%s video codecs:\n%s", description.Type, video)
+		}
+	}
+
+	waitPackets(t, receiver, webrtc.RTPCodecTypeVideo)
+	mutex.Lock()
+	defer mutex.Unlock()
+	var video *RemoteTrackInfo
+	for i := range tracks {
+		if tracks[i].Kind == webrtc.RTPCodecTypeVideo {
+			video = &tracks[i]
+		}
+	}
+	if video == nil || video.MimeType != webrtc.MimeTypeH264 || video.Direction != webrtc.RTPTransceiverDirectionRecvonly {
+		t.Fatalf("remote video track %+v", video)
+	}
+}
+
+func TestRemoveTrackStopsSending(t *testing.T) {
+	sender, receiver := newNegotiatingPeers(t)
+
+	transceiver, err := sender.conn.AddTransceiver(webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverDirectionSendonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendVideo(t, sender, transceiver.Id)
+	waitFor(t, 10*time.Second, "video negotiated", func() bool {
+		return sender.negotiatedDirection("video") == "sendonly"
+	})
+	waitPackets(t, receiver, webrtc.RTPCodecTypeVideo)
+
+	if err := sender.conn.RemoveTrack(transceiver.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.conn.RemoveTrack(transceiver.Id); err == nil {
+		t.Fatal("track removed twice")
+	}
+	waitFor(t, 10*time.Second, "inactive negotiated", func() bool {
+		return sender.negotiatedDirection("video") == "inactive" && receiver.negotiatedDirection("video") == "inactive"
+	})
+	checkNoPackets(t, receiver, webrtc.RTPCodecTypeVideo)
+}
+
+func TestPauseAndResumeTrack(t *testing.T) {
+	sender := newTestPeer(t, "sender", webrtc.Configuration{}, WebRTCSettings{})
+	receiver := newTestPeer(t, "receiver", webrtc.Configuration{}, WebRTCSettings{})
+	connect(t, sender, receiver)
+	sendAudio(t, sender)
+	waitPackets(t, receiver, webrtc.RTPCodecTypeAudio)
+
+	// the default audio track
+	const id = 1
+	if err := sender.conn.PauseTrack(id); err != nil {
+		t.Fatal(err)
+	}
+	if !sender.conn.IsTrackPaused(id) {
+		t.Fatal("track not paused")
+	}
+	checkNoPackets(t, receiver, webrtc.RTPCodecTypeAudio)
+
+	if err := sender.conn.ResumeTrack(id); err != nil {
+		t.Fatal(err)
+	}
+	if sender.conn.IsTrackPaused(id) {
+		t.Fatal("track still paused")
+	}
+	waitPackets(t, receiver, webrtc.RTPCodecTypeAudio)
+
+	// neither needs a new offer
+	if sent := sender.sent.Load(); sent != 1 {
+		t.Fatalf("%d descriptions sent", sent)
+	}
+}
+
+func TestReplaceTrackContinuesStream(t *testing.T) {
+	sender := newTestPeer(t, "sender", webrtc.Configuration{}, WebRTCSettings{})
+	receiver := newTestPeer(t, "receiver", webrtc.Configuration{}, WebRTCSettings{})
+	connect(t, sender, receiver)
+	sendAudio(t, sender)
+	waitPackets(t, receiver, webrtc.RTPCodecTypeAudio)
+
+	const id = 1
+	track, err := sender.conn.NewTransceiverTrack(id, "replacement")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.conn.ReplaceTrack(id, track); err != nil {
+		t.Fatal(err)
+	}
+	waitPackets(t, receiver, webrtc.RTPCodecTypeAudio)
+
+	// the replacement continues the sequence numbers on the same SSRC
+	stats, err := receiver.conn.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.InboundTracks) != 1 {
+		t.Fatalf("%d inbound tracks", len(stats.InboundTracks))
+	}
+	if inbound := stats.InboundTracks[0]; inbound.LocalPacketsLost != 0 || inbound.PacketsLost != 0 {
+		t.Fatalf("packets lost after the replacement: %+v", inbound)
+	}
+
+	// a nil track pauses
+	if err := sender.conn.ReplaceTrack(id, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !sender.conn.IsTrackPaused(id) {
+		t.Fatal("track not paused")
+	}
+	checkNoPackets(t, receiver, webrtc.RTPCodecTypeAudio)
+	if err := sender.conn.ResumeTrack(id); err != nil {
+		t.Fatal(err)
+	}
+	waitPackets(t, receiver, webrtc.RTPCodecTypeAudio)
+
+	video, err := newTrackLocalSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, "video", "stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.conn.ReplaceTrack(id, video); err == nil {
+		t.Fatal("audio track replaced with a video track")
+	}
+}
@@ -0,0 +1,124 @@
+// file: turn_rest.go
+
+package connection
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+const defaultTURNRESTTTL = 24 * time.Hour
+
+// TURNRESTSettings adds a TURN server whose credentials are generated from a
+// shared secret, the "TURN REST API" scheme of coturn (use-auth-secret).
+// The credentials are renewed when three quarters of their TTL have passed,
+// followed by an ICE restart: allocations cannot be refreshed once the
+// credentials they were made with have expired.
+type TURNRESTSettings struct {
+	URLs   []string
+	Secret string
+	// UserID is appended to the expiry time in the username, it may be empty
+	UserID string
+	// TTL is the lifetime of the credentials, 0 selects 24 hours
+	TTL time.Duration
+}
+
+func (s TURNRESTSettings) enabled() bool {
+	return len(s.URLs) > 0 && s.Secret != ""
+}
+
+// TURNRESTCredentials returns TURN credentials that are valid until expiry:
+// the username "<expiry unix time>:<user id>" and the password
+// base64(HMAC-SHA1(secret, username)).
+func TURNRESTCredentials(secret, userID string, expiry time.Time) (username, credential string) {
+	username = strconv.FormatInt(expiry.Unix(), 10)
+	if userID != "" {
+		username += ":" + userID
+	}
+
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+
+	return username, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// turnRESTCredentials holds the current TURN REST server of a connection
+// and renews its credentials.
+type turnRESTCredentials struct {
+	settings TURNRESTSettings
+	done     chan struct{}
+
+	mutex  sync.Mutex
+	server webrtc.ICEServer
+}
+
+func newTURNRESTCredentials(settings TURNRESTSettings) *turnRESTCredentials {
+	if settings.TTL <= 0 {
+		settings.TTL = defaultTURNRESTTTL
+	}
+
+	c := &turnRESTCredentials{
+		settings: settings,
+		done:     make(chan struct{}),
+	}
+	c.renew()
+
+	return c
+}
+
+func (c *turnRESTCredentials) iceServer() webrtc.ICEServer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.server
+}
+
+func (c *turnRESTCredentials) renew() {
+	username, credential := TURNRESTCredentials(c.settings.Secret, c.settings.UserID, time.Now().Add(c.settings.TTL))
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.server = webrtc.ICEServer{
+		URLs:           c.settings.URLs,
+		Username:       username,
+		Credential:     credential,
+		CredentialType: webrtc.ICECredentialTypePassword,
+	}
+}
+
+// run renews the credentials and moves the connection to new allocations
+// until stop.
+func (c *turnRESTCredentials) run(conn *WebRTCConnection) {
+	defer conn.waitGroup.Done()
+
+	ticker := time.NewTicker(c.settings.TTL * 3 / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			conn.iceServersMutex.Lock()
+			c.renew()
+			// failures are logged by updateICEServers
+			err := conn.updateICEServers()
+			conn.iceServersMutex.Unlock()
+			if err == nil {
+				conn.callbacks.LogVerbose("TURN REST credentials renewed")
+				conn.restartICE()
+			}
+		}
+	}
+}
+
+func (c *turnRESTCredentials) stop() {
+	close(c.done)
+}
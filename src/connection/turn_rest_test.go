@@ -0,0 +1,54 @@
+// file: turn_rest_test.go
+
+package connection
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+func TestTURNRESTRenewalKeepsRelayWorking(t *testing.T) {
+	const ttl = 4 * time.Second
+
+	server := newTestTURNServer(t, TURNServerSettings{Secret: "secret"})
+	local := newTestPeer(t, "local", relayConfiguration(), WebRTCSettings{
+		PerfectNegotiation: true,
+		TURNREST:           TURNRESTSettings{URLs: server.turnURLs(), Secret: "secret", TTL: ttl},
+	})
+	remote := newTestPeer(t, "remote", webrtc.Configuration{}, WebRTCSettings{PerfectNegotiation: true, Polite: true})
+	newTestSignaling(t, local, remote)
+	waitConnected(t, local, remote)
+	firstOffer := local.conn.peerConnection.CurrentLocalDescription().SDP
+
+	// the credentials of the first allocation expire after the first renewal
+	waitFor(t, 3*ttl, "two renewals", func() bool {
+		return local.logCount("TURN REST credentials renewed") >= 2
+	})
+	waitFor(t, 15*time.Second, "restart", func() bool {
+		return local.settled() && remote.settled()
+	})
+
+	offer := local.conn.peerConnection.CurrentLocalDescription().SDP
+	if iceUfrag(offer) == iceUfrag(firstOffer) {
+		t.Fatal("renewal did not restart ICE")
+	}
+	if !strings.Contains(offer, " typ relay") {
+		t.Fatal("no relay candidate was gathered with the renewed credentials")
+	}
+	waitConnected(t, local, remote)
+	if pair := selectedPair(t, local); pair.Local.CandidateType != "relay" {
+		t.Fatalf("selected a %s candidate", pair.Local.CandidateType)
+	}
+}
+
+func iceUfrag(sdp string) string {
+	for _, line := range strings.Split(sdp, "\r\n") {
+		if ufrag, found := strings.CutPrefix(line, "a=ice-ufrag:"); found {
+			return ufrag
+		}
+	}
+	return ""
+}
@@ -0,0 +1,221 @@
+// file: turn_server.go
+
+package connection
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/pion/turn/v4"
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	defaultTURNServerAddress = "0.0.0.0:3478"
+	defaultTURNServerRealm   = "pion"
+)
+
+// TURNServerSettings configures the embedded TURN server. Users log in with
+// Username and Password, or with TURN REST credentials made from Secret.
+type TURNServerSettings struct {
+	// ListenAddress is the UDP address of the server, "" selects 0.0.0.0:3478
+	ListenAddress string
+	// TCP also accepts TURN over TCP on the same address
+	TCP bool
+	// Realm "" selects "pion"
+	Realm    string
+	Username string
+	Password string
+	Secret   string
+	// RelayAddress is the IP announced in relay candidates, the peers must
+	// be able to reach it
+	RelayAddress string
+	// RelayPortMin and RelayPortMax restrict the relay ports, 0 selects
+	// ephemeral ports
+	RelayPortMin uint16
+	RelayPortMax uint16
+}
+
+// TURNServer is a TURN server running inside the process. It also answers
+// STUN binding requests.
+type TURNServer struct {
+	settings TURNServerSettings
+	port     int
+	server   *turn.Server
+}
+
+var (
+	turnServer      *TURNServer
+	turnServerMutex sync.Mutex
+)
+
+// StartTURNServer starts the embedded TURN server of the process, connections
+// created with WebRTCSettings.UseTURNServer use it.
+func StartTURNServer(settings TURNServerSettings) error {
+	turnServerMutex.Lock()
+	defer turnServerMutex.Unlock()
+
+	if turnServer != nil {
+		return errors.New("TURN server is already running")
+	}
+
+	server, err := newTURNServer(settings)
+	if err != nil {
+		return err
+	}
+	turnServer = server
+
+	return nil
+}
+
+// StopTURNServer stops the embedded TURN server and drops its allocations.
+func StopTURNServer() error {
+	turnServerMutex.Lock()
+	defer turnServerMutex.Unlock()
+
+	if turnServer == nil {
+		return nil
+	}
+
+	err := turnServer.server.Close()
+	turnServer = nil
+
+	return err
+}
+
+func newTURNServer(settings TURNServerSettings) (*TURNServer, error) {
+	if settings.ListenAddress == "" {
+		settings.ListenAddress = defaultTURNServerAddress
+	}
+	if settings.Realm == "" {
+		settings.Realm = defaultTURNServerRealm
+	}
+	if settings.Username == "" && settings.Secret == "" {
+		return nil, errors.New("TURN server needs a username or a secret")
+	}
+
+	relayIP := net.ParseIP(settings.RelayAddress)
+	if relayIP == nil {
+		return nil, errors.New("invalid TURN relay address: " + settings.RelayAddress)
+	}
+	if settings.RelayPortMin > settings.RelayPortMax {
+		return nil, errors.New("TURN relay port min is greater than max")
+	}
+
+	var relayGenerator turn.RelayAddressGenerator = &turn.RelayAddressGeneratorStatic{
+		RelayAddress: relayIP,
+		Address:      "0.0.0.0",
+	}
+	if settings.RelayPortMax != 0 {
+		relayGenerator = &turn.RelayAddressGeneratorPortRange{
+			RelayAddress: relayIP,
+			MinPort:      settings.RelayPortMin,
+			MaxPort:      settings.RelayPortMax,
+			Address:      "0.0.0.0",
+		}
+	}
+
+	packetConn, err := net.ListenPacket("udp", settings.ListenAddress)
+	if err != nil {
+		return nil, err
+	}
+	// with port 0 TCP listens on the port that was picked for UDP
+	port := packetConn.LocalAddr().(*net.UDPAddr).Port
+
+	config := turn.ServerConfig{
+		Realm:       settings.Realm,
+		AuthHandler: newTURNAuthHandler(settings),
+		PacketConnConfigs: []turn.PacketConnConfig{{
+			PacketConn:            packetConn,
+			RelayAddressGenerator: relayGenerator,
+		}},
+	}
+
+	if settings.TCP {
+		host, _, _ := net.SplitHostPort(settings.ListenAddress)
+		listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			packetConn.Close()
+			return nil, err
+		}
+		config.ListenerConfigs = []turn.ListenerConfig{{
+			Listener:              listener,
+			RelayAddressGenerator: relayGenerator,
+		}}
+	}
+
+	server, err := turn.NewServer(config)
+	if err != nil {
+		packetConn.Close()
+		for _, c := range config.ListenerConfigs {
+			c.Listener.Close()
+		}
+		return nil, err
+	}
+
+	return &TURNServer{settings: settings, port: port, server: server}, nil
+}
+
+func newTURNAuthHandler(settings TURNServerSettings) turn.AuthHandler {
+	var restHandler turn.AuthHandler
+	if settings.Secret != "" {
+		restHandler = turn.LongTermTURNRESTAuthHandler(settings.Secret, nil)
+	}
+
+	return func(username, realm string, srcAddr net.Addr) ([]byte, bool) {
+		if settings.Username != "" && username == settings.Username {
+			return turn.GenerateAuthKey(username, realm, settings.Password), true
+		}
+		if restHandler != nil {
+			return restHandler(username, realm, srcAddr)
+		}
+		return nil, false
+	}
+}
+
+// address is where peers reach the server.
+func (s *TURNServer) address() string {
+	return net.JoinHostPort(s.settings.RelayAddress, strconv.Itoa(s.port))
+}
+
+func (s *TURNServer) turnURLs() []string {
+	address := s.address()
+
+	urls := []string{"turn:" + address}
+	if s.settings.TCP {
+		urls = append(urls, "turn:"+address+"?transport=tcp")
+	}
+
+	return urls
+}
+
+// turnServerICEServers returns how a connection reaches the embedded TURN
+// server: with the username and password of the server, or else with TURN
+// REST settings for its secret.
+func turnServerICEServers() ([]webrtc.ICEServer, TURNRESTSettings, error) {
+	turnServerMutex.Lock()
+	defer turnServerMutex.Unlock()
+
+	if turnServer == nil {
+		return nil, TURNRESTSettings{}, errors.New("TURN server is not running")
+	}
+
+	settings := turnServer.settings
+	servers := []webrtc.ICEServer{{
+		URLs: []string{"stun:" + turnServer.address()},
+	}}
+
+	if settings.Username == "" {
+		return servers, TURNRESTSettings{URLs: turnServer.turnURLs(), Secret: settings.Secret}, nil
+	}
+
+	servers = append(servers, webrtc.ICEServer{
+		URLs:       turnServer.turnURLs(),
+		Username:   settings.Username,
+		Credential: settings.Password,
+	})
+
+	return servers, TURNRESTSettings{}, nil
+}
@@ -4,40 +4,92 @@ package connection
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/interceptor/pkg/stats"
 	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
 	"github.com/pion/webrtc/v4"
 	"github.com/pion/webrtc/v4/pkg/media"
 )
 
 const USE_CUSTOM_TRACK = true
 
+// ErrConnectionClosed is returned by methods that need the pion peer
+// connection after Close.
+var ErrConnectionClosed = errors.New("connection is closed")
+
 type logverbose func(string)
 
 type callicecandidatecallback func(string)
 type calllocaldescriptioncallback func(int, string)
-type callremotetrackcallback func(int, uint32, string, uint32, uint16)
+type callremotetrackcallback func(RemoteTrackInfo)
+type callremotetrackendedcallback func(uint32, string)
 type calltrackdatacallback func(uint32, []byte, int)
+type calltrackpacketcallback func(RTPPacketInfo, []byte)
+type calltrackframecallback func(JitterBufferFrame)
+type callqualitychangedcallback func(QualityEvent)
+type calltargetbitratecallback func(int)
+type callkeyframerequestedcallback func(uint32, KeyframeRequestType)
+type callnegotiationneededcallback func()
 
 type WebRTCCallbacks struct {
-	IceCandidate     callicecandidatecallback
-	LocalDescription calllocaldescriptioncallback
-	RemoteTrackAdded callremotetrackcallback
-	TrackData        calltrackdatacallback
-	LogVerbose       logverbose
+	IceCandidate      callicecandidatecallback
+	LocalDescription  calllocaldescriptioncallback
+	RemoteTrackAdded  callremotetrackcallback
+	RemoteTrackEnded  callremotetrackendedcallback
+	TrackData         calltrackdatacallback
+	TrackPacket       calltrackpacketcallback
+	TrackFrame        calltrackframecallback
+	QualityChanged    callqualitychangedcallback
+	TargetBitrate     calltargetbitratecallback
+	KeyframeRequested callkeyframerequestedcallback
+	NegotiationNeeded callnegotiationneededcallback
+	LogVerbose        logverbose
 }
 
-type TrackDataPacket struct {
-	data []byte
+// RemoteTrackInfo describes a remote track as negotiated by the peer connection.
+// It carries everything the host needs to map the track to a participant and
+// to configure a decoder for it.
+type RemoteTrackInfo struct {
+	Kind        webrtc.RTPCodecType
+	SSRC        uint32
+	MimeType    string
+	ClockRate   uint32
+	Channels    uint16
+	ID          string
+	StreamID    string
+	RID         string
+	Mid         string
+	PayloadType uint8
+	SDPFmtpLine string
+	Direction   webrtc.RTPTransceiverDirection
 }
 
-type ReceiveDataStats struct {
-	NumPackets uint64
-	PacketRate int
+// RTPPacketInfo carries the RTP header fields and the receive timing of a
+// packet delivered through the TrackPacket callback.
+type RTPPacketInfo struct {
+	SSRC           uint32
+	SequenceNumber uint16
+	Timestamp      uint32
+	PayloadType    uint8
+	Marker         bool
+	// ArrivalTime is the local receive time in microseconds since the Unix epoch
+	ArrivalTime int64
+	// Lost is the number of packets missing between the previous packet and this one
+	Lost uint16
+	// RID identifies the simulcast layer, empty for tracks without simulcast
+	RID string
+}
+
+type TrackDataPacket struct {
+	data []byte
 }
 
 type WebRTCDataChannel struct {
@@ -46,21 +98,57 @@ type WebRTCDataChannel struct {
 }
 
 type WebRTCConnection struct {
+	api               *webrtc.API
 	peerConnection    *webrtc.PeerConnection
 	dataChannel       *webrtc.DataChannel
 	dataChannels      []*WebRTCDataChannel
+	transceivers      []*WebRTCTransceiver
 	localSampleTrack  *webrtc.TrackLocalStaticSample
-	customSampleTrack *TrackLocalSample
+	localTransceiver  *WebRTCTransceiver
 	localTrackChannel chan TrackDataPacket
 
-	waitGroup    sync.WaitGroup
-	callbacks    WebRTCCallbacks
-	receiveStats ReceiveDataStats
-
-	nextChannelId int32
+	// held while peerConnection is used by callers racing with Close, see
+	// lockPeerConnection
+	peerConnectionMutex sync.RWMutex
+
+	waitGroup sync.WaitGroup
+	callbacks WebRTCCallbacks
+	settings  WebRTCSettings
+
+	// serializes offer/answer handling
+	signalingMutex sync.Mutex
+	// perfect negotiation state, see negotiation.go
+	ignoreOffer  atomic.Bool
+	offerPending bool
+	// offer sent to the remote but not applied yet, see negotiation.go
+	unappliedOffer *webrtc.SessionDescription
+	// the next offer restarts ICE, see SetICEServers
+	iceRestart bool
+
+	statsMutex     sync.Mutex
+	receiveStats   map[uint32]*ReceiveDataStats
+	statsGetter    atomic.Pointer[stats.Getter]
+	sendQueueDepth atomic.Int64
+	qualityMonitor *qualityMonitor
+
+	// ICE servers set by the host, the TURN REST server is added to them
+	iceServersMutex sync.Mutex
+	iceServers      []webrtc.ICEServer
+	turnREST        *turnRESTCredentials
+
+	targetBitrate atomic.Int64
+
+	firMutex           sync.Mutex
+	firSequenceNumbers map[uint32]uint8
+
+	simulcastMutex     sync.Mutex
+	simulcastReceivers map[string]*simulcastReceiver
+
+	nextChannelId     int32
+	nextTransceiverId int32
 }
 
-func CreatePeerConnection(config webrtc.Configuration, callbacks WebRTCCallbacks) (*WebRTCConnection, error) {
+func CreatePeerConnection(config webrtc.Configuration, settings WebRTCSettings, callbacks WebRTCCallbacks) (*WebRTCConnection, error) {
 
 	// Create a new WebRTC API object
 	var err error
@@ -68,10 +156,7 @@ func CreatePeerConnection(config webrtc.Configuration, callbacks WebRTCCallbacks
 	var peerConnection *webrtc.PeerConnection = nil
 
 	mediaEngine := webrtc.MediaEngine{}
-	if err := mediaEngine.RegisterCodec(webrtc.RTPCodecParameters{
-		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: "audio/opus", ClockRate: 48000, Channels: 1, SDPFmtpLine: "useinbandfec=1;stereo=1;sprop-stereo=1;maxaveragebitrate=96000", RTCPFeedback: nil},
-		PayloadType:        111,
-	}, webrtc.RTPCodecTypeAudio); err != nil {
+	if err := registerCodecs(&mediaEngine); err != nil {
 		//LogError("mediaEngine contained no audio codecs: " + err.Error())
 		return nil, err
 	}
@@ -81,7 +166,86 @@ func CreatePeerConnection(config webrtc.Configuration, callbacks WebRTCCallbacks
 	// 	panic(err)
 	// }
 
-	api := webrtc.NewAPI(webrtc.WithMediaEngine(&mediaEngine))
+	// Negotiate abs-send-time so TrackLocalSample can write it with the agreed id
+	for _, codecType := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeAudio, webrtc.RTPCodecTypeVideo} {
+		if err := mediaEngine.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: sdp.ABSSendTimeURI}, codecType); err != nil {
+			return nil, err
+		}
+	}
+
+	// simulcast layers are told apart by the mid and rid header extensions
+	if err := webrtc.ConfigureSimulcastExtensionHeaders(&mediaEngine); err != nil {
+		return nil, err
+	}
+
+	interceptorFlags := settings.Interceptors
+	if settings.BandwidthEstimation.Enabled {
+		// GCC needs transport-wide congestion control feedback from the remote
+		interceptorFlags = interceptorFlags.resolve() | InterceptorTWCC
+	}
+
+	interceptorRegistry := &interceptor.Registry{}
+
+	// The congestion controller is registered first so that it sees outgoing
+	// packets after the transport-wide sequence number has been added
+	var bandwidthEstimator cc.BandwidthEstimator
+	if settings.BandwidthEstimation.Enabled {
+		err = registerBandwidthEstimator(interceptorRegistry, settings.BandwidthEstimation, func(estimator cc.BandwidthEstimator) {
+			bandwidthEstimator = estimator
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := registerInterceptors(&mediaEngine, interceptorRegistry, interceptorFlags); err != nil {
+		return nil, err
+	}
+
+	var statsGetter stats.Getter
+	err = registerStatsInterceptor(interceptorRegistry, func(g stats.Getter) {
+		statsGetter = g
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	settingEngine, err := newSettingEngine(settings.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	// a lite agent only has host candidates, pion refuses to gather when it
+	// is given STUN or TURN servers
+	iceServers := config.ICEServers
+	var turnREST *turnRESTCredentials
+	if settings.Network.Lite {
+		config.ICEServers = nil
+		config.ICETransportPolicy = webrtc.ICETransportPolicyAll
+		iceServers = nil
+	} else {
+		if settings.UseTURNServer {
+			servers, restSettings, err := turnServerICEServers()
+			if err != nil {
+				return nil, err
+			}
+			iceServers = append(append([]webrtc.ICEServer{}, iceServers...), servers...)
+			if restSettings.enabled() {
+				if settings.TURNREST.enabled() {
+					callbacks.LogVerbose("TURN REST server configured, the embedded TURN server is only used for STUN")
+				} else {
+					settings.TURNREST = restSettings
+				}
+			}
+		}
+		config.ICEServers = iceServers
+		if settings.TURNREST.enabled() {
+			turnREST = newTURNRESTCredentials(settings.TURNREST)
+			config.ICEServers = append(append([]webrtc.ICEServer{}, iceServers...), turnREST.iceServer())
+		}
+	}
+
+	api := webrtc.NewAPI(webrtc.WithMediaEngine(&mediaEngine), webrtc.WithInterceptorRegistry(interceptorRegistry), webrtc.WithSettingEngine(settingEngine))
 
 	peerConnection, err = api.NewPeerConnection(config)
 
@@ -93,11 +257,41 @@ func CreatePeerConnection(config webrtc.Configuration, callbacks WebRTCCallbacks
 	callbacks.LogVerbose("Created peer connection")
 	//LogInfo("peer connection created")
 
-	return &WebRTCConnection{
+	conn := &WebRTCConnection{
+		api:            api,
 		peerConnection: peerConnection,
 		callbacks:      callbacks,
+		settings:       settings,
 		nextChannelId:  1,
-	}, nil
+		iceServers:     iceServers,
+		turnREST:       turnREST,
+	}
+	if statsGetter != nil {
+		conn.statsGetter.Store(&statsGetter)
+	}
+	if bandwidthEstimator != nil {
+		conn.targetBitrate.Store(int64(bandwidthEstimator.GetTargetBitrate()))
+		bandwidthEstimator.OnTargetBitrateChange(conn.targetBitrateHandler)
+	}
+
+	return conn, nil
+}
+
+// TargetBitrate returns the current send bitrate estimate in bits per second,
+// or 0 when bandwidth estimation is disabled.
+func (conn *WebRTCConnection) TargetBitrate() int {
+	return int(conn.targetBitrate.Load())
+}
+
+func (conn *WebRTCConnection) targetBitrateHandler(bitrate int) {
+	if conn.targetBitrate.Swap(int64(bitrate)) == int64(bitrate) {
+		return
+	}
+
+	conn.callbacks.LogVerbose(fmt.Sprintf("target bitrate changed to %d", bitrate))
+	if conn.callbacks.TargetBitrate != nil {
+		conn.callbacks.TargetBitrate(bitrate)
+	}
 }
 
 func (conn *WebRTCConnection) ConnectionState() webrtc.PeerConnectionState {
@@ -149,6 +343,19 @@ func (conn *WebRTCConnection) Init() (err error) {
 
 	conn.peerConnection.OnTrack(conn.trackHandler)
 
+	conn.peerConnection.OnNegotiationNeeded(conn.negotiationNeededHandler)
+
+	if conn.settings.QualityMonitor.Enabled {
+		conn.qualityMonitor = newQualityMonitor(conn, conn.settings.QualityMonitor)
+		conn.waitGroup.Add(1)
+		go conn.qualityMonitor.run()
+	}
+
+	if conn.turnREST != nil {
+		conn.waitGroup.Add(1)
+		go conn.turnREST.run(conn)
+	}
+
 	if USE_CUSTOM_TRACK {
 		err = conn.AddLocalCustomTrack(webrtc.RTPCodecCapability{MimeType: "audio/opus"}, "test", "stream")
 		conn.callbacks.LogVerbose("Added custom sample track")
@@ -178,7 +385,14 @@ func (conn *WebRTCConnection) Close() (err error) {
 	if conn.peerConnection != nil {
 		conn.callbacks.LogVerbose("closing connection...")
 
+		// a renewal must not update the closed connection
+		if conn.turnREST != nil {
+			conn.turnREST.stop()
+		}
+
+		conn.peerConnectionMutex.Lock()
 		err = conn.peerConnection.Close()
+		conn.peerConnectionMutex.Unlock()
 
 		if err != nil {
 			//CallLogCallback("Failed to close connection: "+err.Error(), 0)
@@ -188,32 +402,48 @@ func (conn *WebRTCConnection) Close() (err error) {
 		time.Sleep(1 * time.Second)
 
 		conn.callbacks.LogVerbose("waiting for workers...")
+		if conn.qualityMonitor != nil {
+			conn.qualityMonitor.stop()
+			conn.qualityMonitor = nil
+		}
 		close(conn.localTrackChannel)
 		conn.waitGroup.Wait()
 		conn.callbacks.LogVerbose("workes stopped")
 
 		conn.callbacks.LogVerbose("connection closed")
+		conn.peerConnectionMutex.Lock()
 		conn.peerConnection = nil
+		conn.peerConnectionMutex.Unlock()
 	}
 
 	return err
 }
 
+// lockPeerConnection returns the pion peer connection with the read lock
+// held, so that Close waits until the caller is done with it. Release it
+// with peerConnectionMutex.RUnlock unless ErrConnectionClosed is returned.
+func (conn *WebRTCConnection) lockPeerConnection() (*webrtc.PeerConnection, error) {
+	conn.peerConnectionMutex.RLock()
+	if conn.peerConnection == nil {
+		conn.peerConnectionMutex.RUnlock()
+		return nil, ErrConnectionClosed
+	}
+	return conn.peerConnection, nil
+}
+
 func (conn *WebRTCConnection) AddLocalCustomTrack(c webrtc.RTPCodecCapability, id, streamID string) (err error) {
 
 	// Create an audio track using Opus codec with NewTrackLocalStaticSample
-	rtpTrack, err := webrtc.NewTrackLocalStaticRTP(c, id, streamID)
+	track, err := newTrackLocalSample(c, id, streamID)
 	if err != nil {
 		//LogError("Failed to create static sample")
 		return err
 	}
 
-	conn.customSampleTrack = &TrackLocalSample{
-		rtpTrack: rtpTrack,
-	}
-
-	// Add the media stream and start it
-	_, err = conn.peerConnection.AddTrack(conn.customSampleTrack)
+	// Add the media stream and start it. With a receive only direction the
+	// track is kept until sending is enabled with SetTransceiverDirection.
+	transceiverId := atomic.AddInt32(&conn.nextTransceiverId, 1)
+	conn.localTransceiver, err = conn.addTransceiver(transceiverId, track.Kind(), conn.settings.AudioDirection, track)
 	if err != nil {
 		//LogError("Failed to add track")
 		return err
@@ -240,12 +470,15 @@ func (conn *WebRTCConnection) AddLocalSampleTrack() (err error) {
 	}
 
 	// Add the media stream and start it
-	_, err = conn.peerConnection.AddTrack(conn.localSampleTrack)
+	sender, err := conn.peerConnection.AddTrack(conn.localSampleTrack)
 	if err != nil {
 		//LogError("Failed to add track")
 		return err
 	}
 
+	conn.waitGroup.Add(1)
+	go conn.senderRTCPReader(sender)
+
 	conn.waitGroup.Add(1)
 	// Create a channel for the remote tracks and start reading from it
 	conn.localTrackChannel = make(chan TrackDataPacket, 64)
@@ -255,8 +488,9 @@ func (conn *WebRTCConnection) AddLocalSampleTrack() (err error) {
 }
 
 func (conn *WebRTCConnection) SendLocalTrackPacket(packet TrackDataPacket) (err error) {
-	if conn.customSampleTrack != nil {
-		err = conn.customSampleTrack.WriteSample(media.Sample{
+	if conn.localTransceiver != nil {
+		// the track may have been replaced with ReplaceTrack
+		err = conn.localTransceiver.track.Load().WriteSample(media.Sample{
 			Data:     packet.data,
 			Duration: 20 * time.Millisecond,
 		})
@@ -296,12 +530,14 @@ func (conn *WebRTCConnection) TrackDataSender(ch <-chan TrackDataPacket) {
 				if len(packetBuffer) > 1 {
 					buffered = true
 				}
+				conn.sendQueueDepth.Store(int64(len(packetBuffer)))
 				mu.Unlock()
 
 				if buffered {
 					mu.Lock()
 					p := packetBuffer[0]
 					packetBuffer = packetBuffer[1:]
+					conn.sendQueueDepth.Store(int64(len(packetBuffer)))
 					mu.Unlock()
 					err := conn.SendLocalTrackPacket(p)
 					if err != nil {
@@ -333,6 +569,8 @@ func (conn *WebRTCConnection) TrackDataSender(ch <-chan TrackDataPacket) {
 				lastSendTime = t
 
 				//err = nil
+			} else if conn.localTrackPaused() {
+				// nothing is transmitted while paused, don't fill the gap with silence
 			} else {
 				t := time.Now()
 				if t.After(noDataTimeEnd) && noDataTimeEnd.After(noDataTimeBegin) {
@@ -351,6 +589,33 @@ func (conn *WebRTCConnection) TrackDataSender(ch <-chan TrackDataPacket) {
 	}
 }
 
+func (conn *WebRTCConnection) localTrackPaused() bool {
+	return conn.localTransceiver != nil && conn.localTransceiver.paused.Load()
+}
+
+// negotiationNeededHandler tells the host that a new offer is needed, e.g.
+// after adding a data channel or a transceiver or removing a track. With
+// perfect negotiation the offer is created right away instead.
+func (conn *WebRTCConnection) negotiationNeededHandler() {
+	conn.callbacks.LogVerbose("negotiation needed")
+	if conn.settings.PerfectNegotiation {
+		// creating the offer waits for ICE gathering, don't block pion
+		go conn.negotiate()
+		return
+	}
+
+	if conn.callbacks.NegotiationNeeded != nil {
+		conn.callbacks.NegotiationNeeded()
+	}
+}
+
+// negotiationNeeded signals a change pion does not detect itself. Like pion,
+// the handler is dispatched asynchronously, so the host is not called back
+// from within the call that made the change.
+func (conn *WebRTCConnection) negotiationNeeded() {
+	go conn.negotiationNeededHandler()
+}
+
 // CreateDataChannel creates a new data channel for the WebRTC connection.
 func (conn *WebRTCConnection) CreateDataChannel(label string) (*WebRTCDataChannel, error) {
 	// Create a new data channel
@@ -405,14 +670,37 @@ func (conn *WebRTCConnection) dataChannelHandler(dc *webrtc.DataChannel) {
 // 	return modifiedSDP
 // }
 
+// CreateOffer creates an offer, applies it as local description and passes it
+// to the LocalDescription callback once ICE gathering has completed. It can be
+// called again on a live connection to renegotiate.
 func (conn *WebRTCConnection) CreateOffer() error {
-	offer, err := conn.peerConnection.CreateOffer(nil)
-	if err != nil {
+	conn.signalingMutex.Lock()
+	defer conn.signalingMutex.Unlock()
+
+	return conn.createOffer()
+}
+
+func (conn *WebRTCConnection) createOffer() error {
+	if state := conn.peerConnection.SignalingState(); state != webrtc.SignalingStateStable || conn.unappliedOffer != nil {
+		err := fmt.Errorf("cannot create an offer in signaling state %s", state)
+		if conn.unappliedOffer != nil {
+			err = errors.New("cannot create an offer while the previous one is unanswered")
+		}
 		conn.callbacks.LogVerbose("Failed to create an offer: " + err.Error())
 		return err
 	}
 
-	gatherComplete := webrtc.GatheringCompletePromise(conn.peerConnection)
+	var options *webrtc.OfferOptions
+	if conn.iceRestart {
+		options = &webrtc.OfferOptions{ICERestart: true}
+	}
+
+	offer, err := conn.peerConnection.CreateOffer(options)
+	if err != nil {
+		conn.callbacks.LogVerbose("Failed to create an offer: " + err.Error())
+		return err
+	}
+	conn.iceRestart = false
 
 	// modifiedSDP := addSDPOptions(offer.SDP)
 	// offer = webrtc.SessionDescription{
@@ -420,12 +708,47 @@ func (conn *WebRTCConnection) CreateOffer() error {
 	// 	SDP:  modifiedSDP,
 	// }
 
-	err = conn.peerConnection.SetLocalDescription(offer)
+	if conn.settings.PerfectNegotiation && conn.peerConnection.CurrentLocalDescription() != nil {
+		return conn.publishUnappliedOffer(offer, options != nil)
+	}
+
+	return conn.publishLocalDescription(offer)
+}
+
+// CreateAnswer answers a remote offer set with SetRemoteDescriptionWithType
+// and passes the answer to the LocalDescription callback.
+func (conn *WebRTCConnection) CreateAnswer() error {
+	conn.signalingMutex.Lock()
+	defer conn.signalingMutex.Unlock()
+
+	return conn.createAnswer()
+}
+
+func (conn *WebRTCConnection) createAnswer() error {
+	if state := conn.peerConnection.SignalingState(); state != webrtc.SignalingStateHaveRemoteOffer {
+		err := fmt.Errorf("cannot create an answer in signaling state %s", state)
+		conn.callbacks.LogVerbose("Failed to create an answer: " + err.Error())
+		return err
+	}
+
+	answer, err := conn.peerConnection.CreateAnswer(nil)
+	if err != nil {
+		conn.callbacks.LogVerbose("Failed to create an answer: " + err.Error())
+		return err
+	}
+
+	return conn.publishLocalDescription(answer)
+}
+
+func (conn *WebRTCConnection) publishLocalDescription(description webrtc.SessionDescription) error {
+	gatherComplete := webrtc.GatheringCompletePromise(conn.peerConnection)
+
+	err := conn.peerConnection.SetLocalDescription(description)
 	if err != nil {
-		conn.callbacks.LogVerbose("Failed to set the offer: " + err.Error())
+		conn.callbacks.LogVerbose("Failed to set the " + description.Type.String() + ": " + err.Error())
 		return err
 	}
-	conn.callbacks.LogVerbose("offer created: " + offer.SDP)
+	conn.callbacks.LogVerbose(description.Type.String() + " created: " + description.SDP)
 	<-gatherComplete
 
 	// Get the local description
@@ -439,23 +762,48 @@ func (conn *WebRTCConnection) CreateOffer() error {
 	}
 	conn.callbacks.LogVerbose("local description: " + string(localDescriptionJSON))
 
-	conn.callbacks.LocalDescription(1, offer.SDP)
+	// the candidates are trickled through the IceCandidate callback, unless
+	// the host asked for them in the description
+	descriptionSDP := description.SDP
+	if conn.settings.CandidatesInDescription {
+		descriptionSDP = localDescription.SDP
+	}
+	conn.callbacks.LocalDescription(int(description.Type), descriptionSDP)
 
 	return err
 }
 
+// SetRemoteDescription sets the answer of the remote to our offer.
 func (conn *WebRTCConnection) SetRemoteDescription(sdpString string) error {
+	return conn.SetRemoteDescriptionWithType(webrtc.SDPTypeAnswer, sdpString)
+}
+
+// SetRemoteDescriptionWithType sets a remote offer or answer. A remote offer
+// is answered with CreateAnswer, or automatically with perfect negotiation.
+func (conn *WebRTCConnection) SetRemoteDescriptionWithType(sdpType webrtc.SDPType, sdpString string) error {
+	conn.signalingMutex.Lock()
+	defer conn.signalingMutex.Unlock()
+
 	remoteSDP := webrtc.SessionDescription{
-		Type: webrtc.SDPTypeAnswer,
+		Type: sdpType,
 		SDP:  sdpString,
 	}
 
+	if conn.settings.PerfectNegotiation {
+		return conn.handleRemoteDescription(remoteSDP)
+	}
+
+	return conn.setRemoteDescription(remoteSDP)
+}
+
+func (conn *WebRTCConnection) setRemoteDescription(remoteSDP webrtc.SessionDescription) error {
 	err := conn.peerConnection.SetRemoteDescription(remoteSDP)
 	if err != nil {
 		conn.callbacks.LogVerbose("Failed to set remote description: " + err.Error())
+		return err
 	}
 
-	conn.callbacks.LogVerbose("remote description set to: " + sdpString)
+	conn.callbacks.LogVerbose("remote description set to: " + remoteSDP.SDP)
 
 	return err
 }
@@ -469,6 +817,10 @@ func (conn *WebRTCConnection) AddICECandidate(candidate string) error {
 	err := conn.peerConnection.AddICECandidate(webrtc.ICECandidateInit{
 		Candidate: candidate,
 	})
+	if err != nil && conn.ignoreOffer.Load() {
+		// candidates of an offer ignored during perfect negotiation
+		return nil
+	}
 	if err != nil {
 		conn.callbacks.LogVerbose("AddICECandidate failed: " + err.Error())
 
@@ -507,29 +859,72 @@ func (conn *WebRTCConnection) SendTrackDataPacket(packet []byte) (err error) {
 func (conn *WebRTCConnection) trackHandler(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
 	trackKind := track.Kind()
 
+	conn.waitGroup.Add(1)
+	go conn.receiverRTCPReader(receiver, track.RID())
+
 	if trackKind == webrtc.RTPCodecTypeAudio {
 		conn.audioTrackHandler(track, receiver)
+	} else if trackKind == webrtc.RTPCodecTypeVideo {
+		conn.videoTrackHandler(track, receiver)
+	}
+}
+
+// remoteTrackInfo collects the metadata of a remote track together with the
+// mid and direction of the transceiver that owns its receiver.
+func (conn *WebRTCConnection) remoteTrackInfo(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) RemoteTrackInfo {
+	codec := track.Codec()
+	info := RemoteTrackInfo{
+		Kind:        track.Kind(),
+		SSRC:        uint32(track.SSRC()),
+		MimeType:    codec.MimeType,
+		ClockRate:   codec.ClockRate,
+		Channels:    codec.Channels,
+		ID:          track.ID(),
+		StreamID:    track.StreamID(),
+		RID:         track.RID(),
+		PayloadType: uint8(track.PayloadType()),
+		SDPFmtpLine: codec.SDPFmtpLine,
+	}
+
+	for _, t := range conn.peerConnection.GetTransceivers() {
+		if t.Receiver() == receiver {
+			info.Mid = t.Mid()
+			info.Direction = t.Direction()
+			break
+		}
 	}
+
+	return info
 }
 
-func (conn *WebRTCConnection) audioTrackHandler(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
-	channels := track.Codec().Channels
-	freq := track.Codec().ClockRate
-	mimeType := track.Codec().MimeType
-	formattedString := fmt.Sprintf("received track %s ssrc %d type %s freq %d channels %d payload type %d.", track.Kind().String(), track.SSRC(), mimeType, freq, channels, track.PayloadType())
+func (conn *WebRTCConnection) audioTrackHandler(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+	info := conn.remoteTrackInfo(track, receiver)
+	freq := info.ClockRate
+	formattedString := fmt.Sprintf("received track %s ssrc %d type %s freq %d channels %d payload type %d id %s stream %s rid %s mid %s direction %s fmtp %s.",
+		info.Kind.String(), info.SSRC, info.MimeType, freq, info.Channels, info.PayloadType, info.ID, info.StreamID, info.RID, info.Mid, info.Direction.String(), info.SDPFmtpLine)
 	conn.callbacks.LogVerbose(formattedString)
-	conn.callbacks.RemoteTrackAdded(int(track.Kind()), uint32(track.SSRC()), mimeType, freq, channels)
+	conn.callbacks.RemoteTrackAdded(info)
+	receiveStats := conn.addReceiveStats(info)
 
 	conn.callbacks.LogVerbose("Starting reading from remote track")
 	//bufferSize := freq * 10 / 1000
 	//buffer := make([]byte, 1500)
 	ssrc := uint32(track.SSRC())
+	var pushToJitterBuffer func(*rtp.Packet, time.Time)
 	packetSeen := time.Now()
 	lastPacketCounterCheck := time.Now()
 	numPackets := 0
 	packetRate := 0
 	var lastPacket *rtp.Packet = nil
 	var underrun = false
+
+	if conn.settings.JitterBuffer.Enabled && conn.callbacks.TrackFrame != nil {
+		jitterBuffer := NewJitterBuffer(ssrc, freq, conn.settings.JitterBuffer, conn.callbacks.TrackFrame)
+		defer jitterBuffer.Stop()
+		receiveStats.jitterBuffer.Store(jitterBuffer)
+		pushToJitterBuffer = jitterBuffer.Push
+	}
+
 	for {
 		audioPacket, _, readErr := track.ReadRTP()
 		//len, _, err := track.Read(buffer)
@@ -539,6 +934,10 @@ func (conn *WebRTCConnection) audioTrackHandler(track *webrtc.TrackRemote, _ *we
 		// }
 		if readErr != nil {
 			conn.callbacks.LogVerbose("Error reading from track: " + readErr.Error())
+			// reading stops on any error, so the track has ended for the host
+			if conn.callbacks.RemoteTrackEnded != nil {
+				conn.callbacks.RemoteTrackEnded(ssrc, info.ID)
+			}
 			break
 		}
 		if audioPacket == nil {
@@ -562,35 +961,152 @@ func (conn *WebRTCConnection) audioTrackHandler(track *webrtc.TrackRemote, _ *we
 			}
 		}
 
+		lost := uint16(0)
 		if lastPacket != nil && lastPacket.SequenceNumber+1 != audioPacket.SequenceNumber {
 			formattedString := fmt.Sprintf("audioTrackHandler: Missing packet! sequence number %d and previous one %d", audioPacket.SequenceNumber, lastPacket.SequenceNumber)
 			conn.callbacks.LogVerbose(formattedString)
+			lost = packetsLostBetween(lastPacket.SequenceNumber, audioPacket.SequenceNumber)
+			receiveStats.PacketsLost.Add(uint64(lost))
 		}
 
 		// formattedString := fmt.Sprintf("OnTrack: sequence number %d len %d", audioPacket.SequenceNumber, len(audioPacket.Payload))
 		// CallLogCallback(formattedString, 2)
+		receiveStats.NumPackets.Add(1)
+		receiveStats.NumBytes.Add(uint64(len(audioPacket.Payload)))
+		receiveStats.updateJitter(audioPacket.Timestamp, now)
 		if lastPacket != nil {
 			numPackets++
-			conn.receiveStats.NumPackets = uint64(numPackets)
 			packetCountingDuration := time.Since(lastPacketCounterCheck)
 			if packetCountingDuration > time.Second {
 				lastPacketCounterCheck = time.Now()
 				packetRate = numPackets * 1000 / int(packetCountingDuration.Milliseconds())
 				numPackets = 0
-				conn.receiveStats.PacketRate = packetRate
+				receiveStats.PacketRate.Store(int64(packetRate))
 
 				//timestampDelta := audioPacket.Timestamp - lastPacket.Timestamp
 				//conn.callbacks.LogVerbose(fmt.Sprintf("receive delta: %d timestamp delta: %d packet rate := %d/s", delta.Milliseconds(), timestampDelta, packetRate))
 			}
 		}
 
+		// with the jitter buffer the payload is delivered once, as frame
 		payload := audioPacket.Payload
-		conn.callbacks.TrackData(ssrc, payload, len(payload))
+		if pushToJitterBuffer != nil {
+			pushToJitterBuffer(audioPacket, now)
+		} else {
+			conn.callbacks.TrackData(ssrc, payload, len(payload))
+		}
+
+		if conn.callbacks.TrackPacket != nil {
+			conn.callbacks.TrackPacket(RTPPacketInfo{
+				SSRC:           ssrc,
+				SequenceNumber: audioPacket.SequenceNumber,
+				Timestamp:      audioPacket.Timestamp,
+				PayloadType:    audioPacket.PayloadType,
+				Marker:         audioPacket.Marker,
+				ArrivalTime:    now.UnixMicro(),
+				Lost:           lost,
+			}, payload)
+		}
 
-		lastPacket = audioPacket
+		lastPacket = newestPacket(lastPacket, audioPacket)
 
 		//time.Sleep(10 * time.Millisecond)
 		//CallLogCallback("received "+strconv.Itoa(len), 2)
 		//CallTrackDataCallback(buffer, len(buffer))
 	}
 }
+
+// videoTrackHandler delivers the packets of a remote video track through the
+// TrackPacket callback. Depacketization and decoding is left to the host,
+// which uses RequestKeyframe to recover from losses.
+func (conn *WebRTCConnection) videoTrackHandler(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+	info := conn.remoteTrackInfo(track, receiver)
+	conn.callbacks.LogVerbose(fmt.Sprintf("received track %s ssrc %d type %s freq %d payload type %d id %s stream %s rid %s mid %s direction %s fmtp %s.",
+		info.Kind.String(), info.SSRC, info.MimeType, info.ClockRate, info.PayloadType, info.ID, info.StreamID, info.RID, info.Mid, info.Direction.String(), info.SDPFmtpLine))
+	conn.callbacks.RemoteTrackAdded(info)
+	receiveStats := conn.addReceiveStats(info)
+	if info.RID != "" {
+		conn.addSimulcastLayer(info, receiveStats)
+		defer conn.removeSimulcastLayer(info)
+	}
+
+	ssrc := info.SSRC
+	lastPacketCounterCheck := time.Now()
+	numPackets := 0
+	numBytes := 0
+	var lastPacket *rtp.Packet = nil
+
+	for {
+		videoPacket, _, readErr := track.ReadRTP()
+		if readErr != nil {
+			conn.callbacks.LogVerbose("Error reading from track: " + readErr.Error())
+			// reading stops on any error, so the track has ended for the host
+			if conn.callbacks.RemoteTrackEnded != nil {
+				conn.callbacks.RemoteTrackEnded(ssrc, info.ID)
+			}
+			break
+		}
+
+		now := time.Now()
+		lost := uint16(0)
+		if lastPacket != nil {
+			lost = packetsLostBetween(lastPacket.SequenceNumber, videoPacket.SequenceNumber)
+			receiveStats.PacketsLost.Add(uint64(lost))
+		}
+
+		receiveStats.NumPackets.Add(1)
+		receiveStats.NumBytes.Add(uint64(len(videoPacket.Payload)))
+		receiveStats.updateJitter(videoPacket.Timestamp, now)
+
+		numPackets++
+		numBytes += len(videoPacket.Payload)
+		if packetCountingDuration := now.Sub(lastPacketCounterCheck); packetCountingDuration > time.Second {
+			receiveStats.PacketRate.Store(int64(numPackets * 1000 / int(packetCountingDuration.Milliseconds())))
+			receiveStats.Bitrate.Store(int64(numBytes * 8 * 1000 / int(packetCountingDuration.Milliseconds())))
+			lastPacketCounterCheck = now
+			numPackets = 0
+			numBytes = 0
+
+			if info.RID != "" {
+				conn.updateSimulcastSelection(info.Mid)
+			}
+		}
+
+		// only the selected simulcast layer is delivered
+		if conn.callbacks.TrackPacket != nil && (info.RID == "" || conn.simulcastLayerSelected(info.Mid, info.RID)) {
+			conn.callbacks.TrackPacket(RTPPacketInfo{
+				SSRC:           ssrc,
+				SequenceNumber: videoPacket.SequenceNumber,
+				Timestamp:      videoPacket.Timestamp,
+				PayloadType:    videoPacket.PayloadType,
+				Marker:         videoPacket.Marker,
+				ArrivalTime:    now.UnixMicro(),
+				Lost:           lost,
+				RID:            info.RID,
+			}, videoPacket.Payload)
+		}
+
+		lastPacket = newestPacket(lastPacket, videoPacket)
+	}
+}
+
+// newestPacket returns the packet with the later sequence number. Losses
+// are counted from it, so a late packet must not move it back: the packets
+// between the late one and the newest would be counted as lost again.
+func newestPacket(newest, packet *rtp.Packet) *rtp.Packet {
+	if newest == nil || seqBefore(newest.SequenceNumber, packet.SequenceNumber) {
+		return packet
+	}
+	return newest
+}
+
+// packetsLostBetween returns how many sequence numbers are missing between
+// two consecutive packets. Late or duplicated packets (going backwards in
+// the sequence space) are not counted as losses.
+func packetsLostBetween(prev, current uint16) uint16 {
+	diff := current - prev
+	if diff == 0 || diff >= 0x8000 {
+		return 0
+	}
+	return diff - 1
+}
@@ -0,0 +1,69 @@
+// file: webrtc_connection_test.go
+
+package connection
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+)
+
+func TestRemoteTrackEndedWhenConnectionCloses(t *testing.T) {
+	var added, ended atomic.Uint32
+	sender := newTestPeer(t, "sender", webrtc.Configuration{}, WebRTCSettings{})
+	receiver := newTestPeer(t, "receiver", webrtc.Configuration{}, WebRTCSettings{}, func(callbacks *WebRTCCallbacks) {
+		callbacks.RemoteTrackAdded = func(info RemoteTrackInfo) { added.Store(info.SSRC) }
+		callbacks.RemoteTrackEnded = func(ssrc uint32, trackID string) { ended.Store(ssrc) }
+	})
+	connect(t, sender, receiver)
+	sendAudio(t, sender)
+
+	waitFor(t, 10*time.Second, "remote track", func() bool { return added.Load() != 0 })
+
+	if err := receiver.conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, "track ended", func() bool { return ended.Load() != 0 })
+	if ended.Load() != added.Load() {
+		t.Fatalf("track %d ended, want %d", ended.Load(), added.Load())
+	}
+}
+
+func TestLostPacketsWithReordering(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		sequence  []uint16
+		wantLost  []uint16
+		wantTotal int
+	}{
+		{"in order", []uint16{10, 11, 12}, []uint16{0, 0, 0}, 0},
+		{"gap", []uint16{10, 13}, []uint16{0, 2}, 2},
+		{"late packet", []uint16{10, 8, 11}, []uint16{0, 0, 0}, 0},
+		{"late packet after a gap", []uint16{10, 12, 11, 13}, []uint16{0, 1, 0, 0}, 1},
+		{"duplicate", []uint16{10, 10, 11}, []uint16{0, 0, 0}, 0},
+		{"wrap around", []uint16{65534, 65533, 0}, []uint16{0, 0, 1}, 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var last *rtp.Packet
+			total := 0
+			for i, seq := range test.sequence {
+				packet := &rtp.Packet{Header: rtp.Header{SequenceNumber: seq}}
+				lost := uint16(0)
+				if last != nil {
+					lost = packetsLostBetween(last.SequenceNumber, seq)
+				}
+				if lost != test.wantLost[i] {
+					t.Fatalf("packet %d: %d lost, want %d", seq, lost, test.wantLost[i])
+				}
+				total += int(lost)
+				last = newestPacket(last, packet)
+			}
+			if total != test.wantTotal {
+				t.Fatalf("%d lost in total, want %d", total, test.wantTotal)
+			}
+		})
+	}
+}
@@ -109,18 +109,340 @@ typedef enum {
 	PionSignalingStateClosed
 } PionSignalingState;
 
+typedef enum {
+	PionTransceiverDirectionUnknown,
+
+	// RTPTransceiverDirectionSendrecv indicates the RTPSender will offer
+	// to send RTP and the RTPReceiver will offer to receive RTP.
+	PionTransceiverDirectionSendrecv,
+
+	// RTPTransceiverDirectionSendonly indicates the RTPSender will offer
+	// to send RTP.
+	PionTransceiverDirectionSendonly,
+
+	// RTPTransceiverDirectionRecvonly indicates the RTPReceiver will
+	// offer to receive RTP.
+	PionTransceiverDirectionRecvonly,
+
+	// RTPTransceiverDirectionInactive indicates the RTPSender won't offer
+	// to send RTP and the RTPReceiver won't offer to receive RTP.
+	PionTransceiverDirectionInactive
+} PionTransceiverDirection;
+
+// Type of a session description, passed to local_description_callback and
+// pionSetRemoteDescriptionWithType
+typedef enum {
+	PionSdpTypeUnknown = 0,
+	PionSdpTypeOffer,
+	PionSdpTypePranswer,
+	PionSdpTypeAnswer,
+	PionSdpTypeRollback
+} PionSdpType;
+
+// Layers of a received simulcast track passed to track_packet_callback
+typedef enum {
+	// every layer, told apart by PionTrackPacketInfo.rid
+	PionSimulcastLayerAll = 0,
+	// the layer with the requested RID
+	PionSimulcastLayerRid,
+	// the layer with the highest bitrate
+	PionSimulcastLayerHighest,
+	// the active layer with the lowest bitrate
+	PionSimulcastLayerLowest
+} PionSimulcastLayer;
+
+// ICE candidate type
+typedef enum {
+	PionIceCandidateTypeUnknown = 0,
+	PionIceCandidateTypeHost,
+	PionIceCandidateTypeSrflx,
+	PionIceCandidateTypePrflx,
+	PionIceCandidateTypeRelay
+} PionIceCandidateType;
+
+// Network types used for ICE gathering, combined as flags
+typedef enum {
+	// every supported network type
+	PionNetworkTypeDefault = 0,
+	PionNetworkTypeUDP4 = 1 << 0,
+	PionNetworkTypeUDP6 = 1 << 1,
+	PionNetworkTypeTCP4 = 1 << 2,
+	PionNetworkTypeTCP6 = 1 << 3
+} PionNetworkType;
+
+// Media kind of a track or transceiver
+typedef enum {
+	PionTrackKindUnknown = 0,
+	PionTrackKindAudio = 1,
+	PionTrackKindVideo = 2
+} PionTrackKind;
+
+// Remote track metadata passed to remote_track_info_callback.
+// Strings are only valid for the duration of the callback.
 typedef struct {
+	int kind;
+	unsigned int ssrc;
+	const char* mime_type;
+	unsigned int clock_rate;
+	unsigned short channels;
+	const char* track_id;
+	const char* stream_id;
+	const char* rid;
+	const char* mid;
+	unsigned char payload_type;
+	const char* fmtp_line;
+	PionTransceiverDirection direction;
+} PionRemoteTrackInfo;
+
+// Per-packet RTP metadata passed to track_packet_callback.
+typedef struct {
+	unsigned int ssrc;
+	unsigned short sequence_number;
+	unsigned int timestamp;
+	unsigned char payload_type;
+	int marker;
+	// local receive time in microseconds since the Unix epoch
+	int64_t arrival_time_us;
+	// packets missing between the previous packet and this one
+	unsigned short lost_packets;
+	// simulcast layer, NULL for tracks without simulcast
+	const char* rid;
+} PionTrackPacketInfo;
+
+// Credential of a TURN server
+typedef enum {
+	// credential is the password
+	PionIceCredentialTypePassword = 0,
+	// credential is the OAuth access token, mac_key the base64url encoded
+	// MAC key (RFC 7635)
+	PionIceCredentialTypeOauth
+} PionIceCredentialType;
+
+typedef struct {
+    // STUN or TURN URLs of the server, comma separated, e.g.
+    // "stun:stun.example.com:3478", "turn:turn.example.com:3478?transport=tcp"
+    // or "turn:turn.example.com:3478,turns:turn.example.com:5349?transport=tcp"
     const char* hostname;
     const char* username;
 	const char* credential;
+    // PionIceCredentialType
     int credential_type;
+	const char* mac_key;
 } PionIceServer;
 
+// Candidates used by ICE
+typedef enum {
+	// every candidate type
+	PionIceTransportPolicyAll = 0,
+	// only relay candidates, all traffic goes through a TURN server
+	PionIceTransportPolicyRelay
+} PionIceTransportPolicy;
+
+// Media bundling when the remote endpoint is not bundle-aware
+typedef enum {
+	// balanced
+	PionBundlePolicyDefault = 0,
+	PionBundlePolicyBalanced,
+	PionBundlePolicyMaxCompat,
+	PionBundlePolicyMaxBundle
+} PionBundlePolicy;
+
+// Multiplexing of RTCP on the RTP candidates
+typedef enum {
+	// require
+	PionRtcpMuxPolicyDefault = 0,
+	PionRtcpMuxPolicyNegotiate,
+	PionRtcpMuxPolicyRequire
+} PionRtcpMuxPolicy;
+
+// Interceptor selection flags for PionPeerConnectionConfiguration.interceptors.
+// 0 selects the default set (NACK, RTCP reports and TWCC).
+typedef enum {
+	PionInterceptorDefault = 0,
+	// install none of the optional interceptors when used on its own
+	PionInterceptorNone = 1 << 0,
+	// generate NACKs for lost packets and retransmit on NACK
+	PionInterceptorNACK = 1 << 1,
+	// generate RTCP sender and receiver reports
+	PionInterceptorRTCPReports = 1 << 2,
+	// transport-wide congestion control sequence numbers and feedback
+	PionInterceptorTWCC = 1 << 3
+} PionInterceptor;
+
 typedef struct {
 	const PionIceServer* ice_servers;
 	int num_servers;
+
+	// receive jitter buffer, frames are delivered through track_frame_callback
+	// instead of track_data_callback. The delay adapts to the measured jitter
+	// between min and max.
+	int jitter_buffer_enabled;
+	int jitter_buffer_min_delay_ms;
+	int jitter_buffer_max_delay_ms;
+
+	// connection quality monitor, events are delivered through quality_callback
+	int quality_monitor_enabled;
+	int quality_monitor_interval_ms;
+	float quality_max_packet_loss_percent;
+	int quality_max_jitter_ms;
+	int quality_max_rtt_ms;
+	int quality_min_packet_rate;
+
+	// combination of PionInterceptor flags
+	int interceptors;
+
+	// send side bandwidth estimation (GCC), bitrates in bits per second,
+	// estimates are delivered through target_bitrate_callback
+	int bandwidth_estimation_enabled;
+	int bwe_initial_bitrate;
+	int bwe_min_bitrate;
+	int bwe_max_bitrate;
+
+	// direction of the default audio transceiver, PionTransceiverDirectionUnknown
+	// selects sendrecv. Use recvonly for listener-only clients.
+	PionTransceiverDirection audio_direction;
+
+	// perfect negotiation: offers and answers are created automatically and
+	// offer collisions are resolved, the host forwards every local description
+	// to the remote and every remote description to pionSetRemoteDescriptionWithType.
+	// Exactly one of the two peers sets polite.
+	int perfect_negotiation;
+	int polite;
+
+	// layers of received simulcast tracks that are delivered, can be changed
+	// per track with pionSelectSimulcastLayer
+	PionSimulcastLayer simulcast_layer;
+
+	// UDP port range for ICE, 0 selects ephemeral ports
+	unsigned short ice_port_min;
+	unsigned short ice_port_max;
+	// serve the ICE traffic of all connections from this single UDP port,
+	// 0 disables it. The port range is not used with the mux.
+	int ice_udp_mux_port;
+
+	// public addresses of a static NAT, comma separated, e.g.
+	// "203.0.113.4,2001:db8::4" or "203.0.113.4/10.0.0.4" to map a single
+	// local address. NULL disables the mapping.
+	const char* nat_1to1_ips;
+	// PionIceCandidateTypeHost (default) replaces the local addresses in host
+	// candidates, PionIceCandidateTypeSrflx adds srflx candidates instead and
+	// takes one public address per IP family without local addresses
+	PionIceCandidateType nat_1to1_candidate_type;
+
+	// combination of PionNetworkType flags
+	int ice_network_types;
+	// network interfaces used for gathering, comma separated name patterns
+	// such as "eth*". NULL allows every interface that is not denied.
+	const char* ice_interface_allow_list;
+	const char* ice_interface_deny_list;
+	// local addresses used for gathering, comma separated CIDR ranges or
+	// IPs such as "10.0.0.0/8,fd00::1". NULL allows every address that is
+	// not denied.
+	const char* ice_ip_allow_list;
+	const char* ice_ip_deny_list;
+
+	// ICE timeouts, 0 keeps the default given in parentheses.
+	// Time without traffic before the connection is disconnected (5000)
+	int ice_disconnected_timeout_ms;
+	// time in the disconnected state before the connection fails (25000)
+	int ice_failed_timeout_ms;
+	// interval of the consent checks while there is no other traffic (2000)
+	int ice_keepalive_interval_ms;
+	// minimum time before a pair with a candidate of the type is nominated,
+	// host (0), srflx (500), prflx (1000) and relay (2000)
+	int ice_host_acceptance_min_wait_ms;
+	int ice_srflx_acceptance_min_wait_ms;
+	int ice_prflx_acceptance_min_wait_ms;
+	int ice_relay_acceptance_min_wait_ms;
+
+	// TCP port of passive ICE-TCP candidates for networks that block UDP,
+	// 0 disables them. Shared by all connections like the UDP mux.
+	int ice_tcp_mux_port;
+
+	// run an ICE-lite agent that only gathers host candidates, for servers
+	// with a public address. The remote peer must run full ICE.
+	int ice_lite;
+
+	PionIceTransportPolicy ice_transport_policy;
+	PionBundlePolicy bundle_policy;
+	PionRtcpMuxPolicy rtcp_mux_policy;
+
+	// TURN server with credentials generated from a shared secret ("TURN
+	// REST API", coturn use-auth-secret). turn_rest_urls are comma separated
+	// TURN URLs, the credentials are renewed before they expire. NULL urls
+	// or secret disable it.
+	const char* turn_rest_urls;
+	const char* turn_rest_secret;
+	// appended to the expiry time in the username, may be NULL
+	const char* turn_rest_user_id;
+	// lifetime of the credentials, 0 selects 86400 (24 hours)
+	int turn_rest_ttl_s;
+
+	// add the embedded TURN server started with pionStartTurnServer to the
+	// ICE servers, as STUN and TURN server
+	int use_turn_server;
+
+	// DTLS certificate and PKCS #8 private key as PEM, e.g. from
+	// pionGenerateCertificate, so the fingerprint stays the same across
+	// sessions. NULL generates a new certificate per connection.
+	const char* certificate_pem;
+
+	// pass local descriptions with the gathered candidates to
+	// local_description_callback, for hosts that don't forward the
+	// candidates of ice_candidate_callback
+	int local_description_with_candidates;
 } PionPeerConnectionConfiguration;
 
+// Embedded TURN server, started with pionStartTurnServer. Clients log in with
+// username and password, or with TURN REST credentials made from auth_secret.
+typedef struct {
+	// UDP address to listen on, NULL selects "0.0.0.0:3478"
+	const char* listen_address;
+	// also accept TURN over TCP on the same address
+	int tcp;
+	// NULL selects "pion"
+	const char* realm;
+	const char* username;
+	const char* password;
+	// shared secret for TURN REST API credentials, may be NULL
+	const char* auth_secret;
+	// IP announced in relay candidates, must be reachable by the peers
+	const char* relay_address;
+	// range of the relay ports, 0 selects ephemeral ports
+	unsigned short relay_port_min;
+	unsigned short relay_port_max;
+} PionTurnServerConfiguration;
+
+// RTCP message used to request a keyframe
+typedef enum {
+	// Picture Loss Indication (RFC 4585)
+	PionKeyframeRequestPLI = 0,
+	// Full Intra Request (RFC 5104)
+	PionKeyframeRequestFIR
+} PionKeyframeRequestType;
+
+// Connection quality event passed to quality_callback. The reason lists the
+// metrics that crossed their thresholds or is "recovered".
+typedef struct {
+	int score;
+	int degraded;
+	const char* reason;
+	float packet_loss_percent;
+	int jitter_ms;
+	int rtt_ms;
+	int packet_rate;
+} PionQualityEvent;
+
+// Frame released by the receive jitter buffer and passed to track_frame_callback.
+// When lost is set no payload is passed and the decoder should conceal the frame.
+typedef struct {
+	unsigned int ssrc;
+	unsigned short sequence_number;
+	unsigned int timestamp;
+	int lost;
+	unsigned int target_delay_ms;
+} PionTrackFrameInfo;
+
 // Example of function declaration in C
 extern void onMessage(uint8_t* msg, int len);
 extern void onIceCandidate(const char* candidate);
@@ -145,21 +467,63 @@ static void helper_local_description(localdescriptioncb f, int type, const char*
 typedef void (*remotetrackcb)(int, unsigned int, const char*, unsigned int, unsigned short);
 static void helper_remote_track(remotetrackcb f, int kind, unsigned int ssrc, const char* mime, unsigned int sample_rate, unsigned short channels) { f(kind, ssrc, mime, sample_rate, channels); }
 
+// helper to call new track callback with full track metadata
+typedef void (*remotetrackinfocb)(const PionRemoteTrackInfo*);
+static void helper_remote_track_info(remotetrackinfocb f, const PionRemoteTrackInfo* info) { f(info); }
+
+// helper to call track ended callback
+typedef void (*remotetrackendedcb)(unsigned int, const char*);
+static void helper_remote_track_ended(remotetrackendedcb f, unsigned int ssrc, const char* track_id) { f(ssrc, track_id); }
+
 // helper to call new track callback
 typedef void (*trackdatacb)(unsigned int, const char*, unsigned int);
 static void helper_track_data(trackdatacb f, unsigned int ssrc, const char* data, unsigned int length) { f(ssrc, data, length); }
 
+// helper to call track packet callback
+typedef void (*trackpacketcb)(const PionTrackPacketInfo*, const char*, unsigned int);
+static void helper_track_packet(trackpacketcb f, const PionTrackPacketInfo* info, const char* data, unsigned int length) { f(info, data, length); }
+
+// helper to call jitter buffer frame callback
+typedef void (*trackframecb)(const PionTrackFrameInfo*, const char*, unsigned int);
+static void helper_track_frame(trackframecb f, const PionTrackFrameInfo* info, const char* data, unsigned int length) { f(info, data, length); }
+
+// helper to call connection quality callback
+typedef void (*qualitycb)(const PionQualityEvent*);
+static void helper_quality(qualitycb f, const PionQualityEvent* event) { f(event); }
+
+// helper to call target bitrate callback
+typedef void (*targetbitratecb)(int);
+static void helper_target_bitrate(targetbitratecb f, int bitrate) { f(bitrate); }
+
+// helper to call keyframe requested callback
+typedef void (*keyframerequestcb)(unsigned int, PionKeyframeRequestType);
+static void helper_keyframe_request(keyframerequestcb f, unsigned int ssrc, PionKeyframeRequestType type) { f(ssrc, type); }
+
+// helper to call negotiation needed callback
+typedef void (*negotiationneededcb)();
+static void helper_negotiation_needed(negotiationneededcb f) { f(); }
+
 typedef struct {
 	logcb log_callback;
 	icecandidatecb ice_candidate_callback;
 	localdescriptioncb local_description_callback;
 	remotetrackcb remote_track_callback;
 	trackdatacb track_data_callback;
+	remotetrackinfocb remote_track_info_callback;
+	remotetrackendedcb remote_track_ended_callback;
+	trackpacketcb track_packet_callback;
+	trackframecb track_frame_callback;
+	qualitycb quality_callback;
+	targetbitratecb target_bitrate_callback;
+	keyframerequestcb keyframe_request_callback;
+	negotiationneededcb negotiation_needed_callback;
 } PionCallbacks;
 */
 import "C"
 import (
 	"pionc/connection"
+	"strings"
+	"time"
 	"unsafe"
 
 	"github.com/pion/webrtc/v4"
@@ -222,11 +586,55 @@ func CallLocalDescriptionCallback(desc_type int, sdp string) {
 	C.free(unsafe.Pointer(csdp))
 }
 
-// CallRemoteTrackCallback(C.int(track.Kind()), track.SSRC(), mimeType, freq, channels)
-func CallRemoteTrackCallback(kind int, ssrc uint32, mime string, freq uint32, channels uint16) {
-	var cmime_type = C.CString(mime)
-	C.helper_remote_track(pion_callbacks.remote_track_callback, C.int(kind), C.uint(ssrc), cmime_type, C.uint(freq), C.ushort(channels))
-	C.free(unsafe.Pointer(cmime_type))
+// CallRemoteTrackCallback reports a new remote track to both the legacy
+// remote_track_callback and remote_track_info_callback, whichever are set.
+func CallRemoteTrackCallback(info connection.RemoteTrackInfo) {
+	var cmime_type = C.CString(info.MimeType)
+	defer C.free(unsafe.Pointer(cmime_type))
+
+	if pion_callbacks.remote_track_callback != nil {
+		C.helper_remote_track(pion_callbacks.remote_track_callback, C.int(info.Kind), C.uint(info.SSRC), cmime_type, C.uint(info.ClockRate), C.ushort(info.Channels))
+	}
+
+	if pion_callbacks.remote_track_info_callback != nil {
+		var ctrack_id = C.CString(info.ID)
+		var cstream_id = C.CString(info.StreamID)
+		var crid = C.CString(info.RID)
+		var cmid = C.CString(info.Mid)
+		var cfmtp = C.CString(info.SDPFmtpLine)
+
+		cinfo := C.PionRemoteTrackInfo{
+			kind:         C.int(info.Kind),
+			ssrc:         C.uint(info.SSRC),
+			mime_type:    cmime_type,
+			clock_rate:   C.uint(info.ClockRate),
+			channels:     C.ushort(info.Channels),
+			track_id:     ctrack_id,
+			stream_id:    cstream_id,
+			rid:          crid,
+			mid:          cmid,
+			payload_type: C.uchar(info.PayloadType),
+			fmtp_line:    cfmtp,
+			direction:    C.PionTransceiverDirection(info.Direction),
+		}
+		C.helper_remote_track_info(pion_callbacks.remote_track_info_callback, &cinfo)
+
+		C.free(unsafe.Pointer(ctrack_id))
+		C.free(unsafe.Pointer(cstream_id))
+		C.free(unsafe.Pointer(crid))
+		C.free(unsafe.Pointer(cmid))
+		C.free(unsafe.Pointer(cfmtp))
+	}
+}
+
+func CallRemoteTrackEndedCallback(ssrc uint32, trackID string) {
+	if pion_callbacks.remote_track_ended_callback == nil {
+		return
+	}
+
+	var ctrack_id = C.CString(trackID)
+	C.helper_remote_track_ended(pion_callbacks.remote_track_ended_callback, C.uint(ssrc), ctrack_id)
+	C.free(unsafe.Pointer(ctrack_id))
 }
 
 func CallTrackDataCallback(ssrc uint32, data []byte, len int) {
@@ -235,6 +643,110 @@ func CallTrackDataCallback(ssrc uint32, data []byte, len int) {
 	//C.free(unsafe.Pointer(cdata))
 }
 
+func CallTrackPacketCallback(info connection.RTPPacketInfo, data []byte) {
+	if pion_callbacks.track_packet_callback == nil {
+		return
+	}
+
+	marker := 0
+	if info.Marker {
+		marker = 1
+	}
+
+	cinfo := C.PionTrackPacketInfo{
+		ssrc:            C.uint(info.SSRC),
+		sequence_number: C.ushort(info.SequenceNumber),
+		timestamp:       C.uint(info.Timestamp),
+		payload_type:    C.uchar(info.PayloadType),
+		marker:          C.int(marker),
+		arrival_time_us: C.int64_t(info.ArrivalTime),
+		lost_packets:    C.ushort(info.Lost),
+	}
+	if info.RID != "" {
+		cinfo.rid = C.CString(info.RID)
+		defer C.free(unsafe.Pointer(cinfo.rid))
+	}
+
+	var cdata *C.char
+	if len(data) > 0 {
+		cdata = (*C.char)(unsafe.Pointer(&data[0]))
+	}
+	C.helper_track_packet(pion_callbacks.track_packet_callback, &cinfo, cdata, C.uint(len(data)))
+}
+
+func CallTrackFrameCallback(frame connection.JitterBufferFrame) {
+	if pion_callbacks.track_frame_callback == nil {
+		return
+	}
+
+	lost := 0
+	if frame.Lost {
+		lost = 1
+	}
+
+	cinfo := C.PionTrackFrameInfo{
+		ssrc:            C.uint(frame.SSRC),
+		sequence_number: C.ushort(frame.SequenceNumber),
+		timestamp:       C.uint(frame.Timestamp),
+		lost:            C.int(lost),
+		target_delay_ms: C.uint(frame.TargetDelay.Milliseconds()),
+	}
+
+	var cdata *C.char
+	if len(frame.Payload) > 0 {
+		cdata = (*C.char)(unsafe.Pointer(&frame.Payload[0]))
+	}
+	C.helper_track_frame(pion_callbacks.track_frame_callback, &cinfo, cdata, C.uint(len(frame.Payload)))
+}
+
+func CallQualityCallback(event connection.QualityEvent) {
+	if pion_callbacks.quality_callback == nil {
+		return
+	}
+
+	degraded := 0
+	if event.Degraded {
+		degraded = 1
+	}
+
+	var creason = C.CString(event.Reason)
+	cevent := C.PionQualityEvent{
+		score:               C.int(event.Score),
+		degraded:            C.int(degraded),
+		reason:              creason,
+		packet_loss_percent: C.float(event.PacketLoss * 100),
+		jitter_ms:           C.int(event.Jitter.Milliseconds()),
+		rtt_ms:              C.int(event.RoundTripTime.Milliseconds()),
+		packet_rate:         C.int(event.PacketRate),
+	}
+	C.helper_quality(pion_callbacks.quality_callback, &cevent)
+	C.free(unsafe.Pointer(creason))
+}
+
+func CallTargetBitrateCallback(bitrate int) {
+	if pion_callbacks.target_bitrate_callback == nil {
+		return
+	}
+
+	C.helper_target_bitrate(pion_callbacks.target_bitrate_callback, C.int(bitrate))
+}
+
+func CallKeyframeRequestCallback(ssrc uint32, requestType connection.KeyframeRequestType) {
+	if pion_callbacks.keyframe_request_callback == nil {
+		return
+	}
+
+	C.helper_keyframe_request(pion_callbacks.keyframe_request_callback, C.uint(ssrc), C.PionKeyframeRequestType(requestType))
+}
+
+func CallNegotiationNeededCallback() {
+	if pion_callbacks.negotiation_needed_callback == nil {
+		return
+	}
+
+	C.helper_negotiation_needed(pion_callbacks.negotiation_needed_callback)
+}
+
 // ============================================================================
 // Go-to-C interface
 // ============================================================================
@@ -255,13 +767,25 @@ func pionCreatePeerConnection(config *C.PionPeerConnectionConfiguration) int {
 
 	pionClosePeerConnection()
 
-	var err error
-	pionConnection, err = connection.CreatePeerConnection(createPeerConnectionConfig(config), connection.WebRTCCallbacks{
-		IceCandidate:     CallIceCandidateCallback,
-		LocalDescription: CallLocalDescriptionCallback,
-		RemoteTrackAdded: CallRemoteTrackCallback,
-		TrackData:        CallTrackDataCallback,
-		LogVerbose:       logger})
+	pionConfig, err := createPeerConnectionConfig(config)
+	if err != nil {
+		LogError("Failed to import certificate: " + err.Error())
+		return -1
+	}
+
+	pionConnection, err = connection.CreatePeerConnection(pionConfig, createPeerConnectionSettings(config), connection.WebRTCCallbacks{
+		IceCandidate:      CallIceCandidateCallback,
+		LocalDescription:  CallLocalDescriptionCallback,
+		RemoteTrackAdded:  CallRemoteTrackCallback,
+		RemoteTrackEnded:  CallRemoteTrackEndedCallback,
+		TrackData:         CallTrackDataCallback,
+		TrackPacket:       CallTrackPacketCallback,
+		TrackFrame:        CallTrackFrameCallback,
+		QualityChanged:    CallQualityCallback,
+		TargetBitrate:     CallTargetBitrateCallback,
+		KeyframeRequested: CallKeyframeRequestCallback,
+		NegotiationNeeded: CallNegotiationNeededCallback,
+		LogVerbose:        logger})
 	if err != nil {
 		LogError("Failed to create peer connection: " + err.Error())
 		return -1
@@ -272,6 +796,79 @@ func pionCreatePeerConnection(config *C.PionPeerConnectionConfiguration) int {
 	return 1
 }
 
+// Replaces the STUN and TURN servers of the connection, e.g. with rotated
+// TURN credentials. With restart set the next offer restarts ICE;
+// negotiation_needed_callback asks for it unless perfect negotiation is
+// enabled.
+//
+//export pionSetIceServers
+func pionSetIceServers(servers *C.PionIceServer, numServers C.int, restart C.int) {
+	if pionConnection != nil {
+		err := pionConnection.SetICEServers(createICEServers(servers, numServers), restart != 0)
+		if err != nil {
+			LogError("Failed to set ICE servers: " + err.Error())
+		}
+	}
+}
+
+// Generates TURN REST API credentials valid for ttlS seconds from the shared
+// secret: username is "<expiry>:<user id>" and credential the base64
+// encoded HMAC-SHA1 of the username. Both strings must be released with
+// pionFreeString.
+//
+//export pionCreateTurnRestCredentials
+func pionCreateTurnRestCredentials(secret *C.char, userId *C.char, ttlS C.int, username **C.char, credential **C.char) {
+	if username == nil || credential == nil {
+		LogError("Failed to create TURN REST credentials: username and credential must not be NULL")
+		return
+	}
+
+	user, password := connection.TURNRESTCredentials(C.GoString(secret), C.GoString(userId), time.Now().Add(time.Duration(ttlS)*time.Second))
+	*username = C.CString(user)
+	*credential = C.CString(password)
+}
+
+// Starts the embedded TURN server of the process, for local testing and
+// small deployments. Connections reference it with use_turn_server. Returns
+// 1 on success.
+//
+//export pionStartTurnServer
+func pionStartTurnServer(config *C.PionTurnServerConfiguration) int {
+	if config == nil {
+		LogError("Failed to start TURN server: configuration is NULL")
+		return C.PionErrorCodeInvalid
+	}
+
+	err := connection.StartTURNServer(connection.TURNServerSettings{
+		ListenAddress: C.GoString(config.listen_address),
+		TCP:           config.tcp != 0,
+		Realm:         C.GoString(config.realm),
+		Username:      C.GoString(config.username),
+		Password:      C.GoString(config.password),
+		Secret:        C.GoString(config.auth_secret),
+		RelayAddress:  C.GoString(config.relay_address),
+		RelayPortMin:  uint16(config.relay_port_min),
+		RelayPortMax:  uint16(config.relay_port_max),
+	})
+	if err != nil {
+		LogError("Failed to start TURN server: " + err.Error())
+		return C.PionErrorCodeInvalid
+	}
+
+	LogInfo("Started TURN server")
+
+	return 1
+}
+
+// Stops the embedded TURN server, relayed connections through it fail.
+//
+//export pionStopTurnServer
+func pionStopTurnServer() {
+	if err := connection.StopTURNServer(); err != nil {
+		LogError("Failed to stop TURN server: " + err.Error())
+	}
+}
+
 //export pionCreateDataChannel
 func pionCreateDataChannel(label *C.char) int32 {
 	if pionConnection != nil {
@@ -342,6 +939,27 @@ func pionSetRemoteDescription(sdp *C.char) {
 	}
 }
 
+// Answers a remote offer set with pionSetRemoteDescriptionWithType, the
+// answer is passed to local_description_callback.
+//
+//export pionCreateAnswer
+func pionCreateAnswer() {
+	if pionConnection != nil {
+		pionConnection.CreateAnswer()
+	}
+}
+
+//export pionSetRemoteDescriptionWithType
+func pionSetRemoteDescriptionWithType(sdpType C.PionSdpType, sdp *C.char) {
+	if pionConnection != nil {
+		goSdp := C.GoString(sdp)
+		err := pionConnection.SetRemoteDescriptionWithType(webrtc.SDPType(sdpType), goSdp)
+		if err != nil {
+			LogError("Failed to set remote description: " + err.Error())
+		}
+	}
+}
+
 //export pionAddICECandidate
 func pionAddICECandidate(candidate *C.char) {
 	if pionConnection != nil {
@@ -385,46 +1003,457 @@ func pionSendTrackDataPacket(data *C.char, length C.int) {
 	}
 }
 
+// Adds a transceiver and returns its id, or PionErrorCodeInvalid on failure.
+// The default audio transceiver has id 1. A new offer is needed to announce
+// the transceiver to the remote.
+//
+//export pionAddTransceiver
+func pionAddTransceiver(kind C.PionTrackKind, direction C.PionTransceiverDirection) int32 {
+	if pionConnection != nil {
+		t, err := pionConnection.AddTransceiver(webrtc.RTPCodecType(kind), webrtc.RTPTransceiverDirection(direction))
+		if err != nil {
+			LogError("Failed to add transceiver: " + err.Error())
+			return C.PionErrorCodeInvalid
+		}
+
+		return t.Id
+	}
+
+	return C.PionErrorCodeInvalid
+}
+
+// Starts or stops sending on a transceiver (sendrecv <-> recvonly,
+// sendonly <-> inactive). A new offer is needed to apply the change.
+//
+//export pionSetTransceiverDirection
+func pionSetTransceiverDirection(id int32, direction C.PionTransceiverDirection) {
+	if pionConnection != nil {
+		err := pionConnection.SetTransceiverDirection(id, webrtc.RTPTransceiverDirection(direction))
+		if err != nil {
+			LogError("Failed to set transceiver direction: " + err.Error())
+		}
+	}
+}
+
+//export pionGetTransceiverDirection
+func pionGetTransceiverDirection(id int32) C.PionTransceiverDirection {
+	if pionConnection != nil {
+		return C.PionTransceiverDirection(pionConnection.GetTransceiverDirection(id))
+	}
+
+	return C.PionTransceiverDirectionUnknown
+}
+
+// Stops sending the local track of a transceiver, negotiation_needed_callback
+// is called to request a new offer.
+//
+//export pionRemoveTrack
+func pionRemoveTrack(id int32) {
+	if pionConnection != nil {
+		err := pionConnection.RemoveTrack(id)
+		if err != nil {
+			LogError("Failed to remove track: " + err.Error())
+		}
+	}
+}
+
+// Adds a send only video transceiver with one simulcast layer per RID, given
+// as a comma separated list, e.g. "q,h,f". Returns the transceiver id or
+// PionErrorCodeInvalid on failure.
+//
+//export pionAddSimulcastTransceiver
+func pionAddSimulcastTransceiver(rids *C.char) int32 {
+	if pionConnection != nil {
+		t, err := pionConnection.AddSimulcastTransceiver(strings.Split(C.GoString(rids), ","))
+		if err != nil {
+			LogError("Failed to add simulcast transceiver: " + err.Error())
+			return C.PionErrorCodeInvalid
+		}
+
+		return t.Id
+	}
+
+	return C.PionErrorCodeInvalid
+}
+
+// Sends an encoded video frame on the simulcast layer with the given RID.
+//
+//export pionSendSimulcastSample
+func pionSendSimulcastSample(id int32, rid *C.char, data *C.char, length C.int, durationUs C.int) {
+	if pionConnection != nil {
+		goBytes := C.GoBytes(unsafe.Pointer(data), length)
+		err := pionConnection.WriteSimulcastSample(id, C.GoString(rid), goBytes, time.Duration(durationUs)*time.Microsecond)
+		if err != nil {
+			LogError("Failed to send simulcast sample: " + err.Error())
+		}
+	}
+}
+
+// Selects the layers of the simulcast track received on the transceiver with
+// the given mid (see PionRemoteTrackInfo.mid). rid is only used with
+// PionSimulcastLayerRid.
+//
+//export pionSelectSimulcastLayer
+func pionSelectSimulcastLayer(mid *C.char, layer C.PionSimulcastLayer, rid *C.char) {
+	if pionConnection != nil {
+		err := pionConnection.SelectSimulcastLayer(C.GoString(mid), connection.SimulcastLayerMode(layer), C.GoString(rid))
+		if err != nil {
+			LogError("Failed to select simulcast layer: " + err.Error())
+		}
+	}
+}
+
+// Replaces the local track of a transceiver with a new track using the same
+// codec, without a new offer. A NULL or empty track id replaces it with
+// nothing, which stops transmission like pionPauseTrack.
+//
+//export pionReplaceTrack
+func pionReplaceTrack(id int32, trackId *C.char) {
+	if pionConnection != nil {
+		var track *connection.TrackLocalSample
+		if trackId != nil && C.GoString(trackId) != "" {
+			var err error
+			track, err = pionConnection.NewTransceiverTrack(id, C.GoString(trackId))
+			if err != nil {
+				LogError("Failed to create track: " + err.Error())
+				return
+			}
+		}
+
+		err := pionConnection.ReplaceTrack(id, track)
+		if err != nil {
+			LogError("Failed to replace track: " + err.Error())
+		}
+	}
+}
+
+// Stops sending the local track of a transceiver without a new offer. The
+// default audio track sends no silence while paused.
+//
+//export pionPauseTrack
+func pionPauseTrack(id int32) {
+	if pionConnection != nil {
+		err := pionConnection.PauseTrack(id)
+		if err != nil {
+			LogError("Failed to pause track: " + err.Error())
+		}
+	}
+}
+
+//export pionResumeTrack
+func pionResumeTrack(id int32) {
+	if pionConnection != nil {
+		err := pionConnection.ResumeTrack(id)
+		if err != nil {
+			LogError("Failed to resume track: " + err.Error())
+		}
+	}
+}
+
+//export pionIsTrackPaused
+func pionIsTrackPaused(id int32) C.int {
+	if pionConnection != nil && pionConnection.IsTrackPaused(id) {
+		return 1
+	}
+
+	return 0
+}
+
+// Sets the codecs negotiated for a transceiver as a comma separated list of
+// mime types in order of preference, e.g. "video/H264,video/VP8". An empty
+// list restores the defaults.
+//
+//export pionSetTransceiverCodecPreferences
+func pionSetTransceiverCodecPreferences(id int32, mimeTypes *C.char) {
+	if pionConnection != nil {
+		err := pionConnection.SetTransceiverCodecPreferences(id, strings.Split(C.GoString(mimeTypes), ","))
+		if err != nil {
+			LogError("Failed to set codec preferences: " + err.Error())
+		}
+	}
+}
+
+// Sends an encoded audio or video frame on the local track of a transceiver.
+//
+//export pionSendTransceiverSample
+func pionSendTransceiverSample(id int32, data *C.char, length C.int, durationUs C.int) {
+	if pionConnection != nil {
+		goBytes := C.GoBytes(unsafe.Pointer(data), length)
+		err := pionConnection.WriteTransceiverSample(id, goBytes, time.Duration(durationUs)*time.Microsecond)
+		if err != nil {
+			LogError("Failed to send transceiver sample: " + err.Error())
+		}
+	}
+}
+
+// Asks the remote sender of the track with the given SSRC for a keyframe.
+//
+//export pionRequestKeyframe
+func pionRequestKeyframe(ssrc C.uint, requestType C.PionKeyframeRequestType) {
+	if pionConnection != nil {
+		err := pionConnection.RequestKeyframe(uint32(ssrc), connection.KeyframeRequestType(requestType))
+		if err != nil {
+			LogError("Failed to request keyframe: " + err.Error())
+		}
+	}
+}
+
+// Returns the current send bitrate estimate in bits per second, 0 when
+// bandwidth estimation is disabled.
+//
+//export pionGetTargetBitrate
+func pionGetTargetBitrate() int32 {
+	if pionConnection != nil {
+		return int32(pionConnection.TargetBitrate())
+	}
+
+	return 0
+}
+
+// Returns a JSON snapshot of the connection statistics or NULL when there is
+// no connection. The returned string must be released with pionFreeString.
+//
+//export pionGetStats
+func pionGetStats() *C.char {
+	if pionConnection != nil {
+		stats, err := pionConnection.GetStatsJSON()
+		if err != nil {
+			LogError("Failed to get stats: " + err.Error())
+			return nil
+		}
+		return C.CString(stats)
+	}
+
+	return nil
+}
+
+// Generates a DTLS certificate valid for validityDays (0 selects 365) and
+// returns it with its private key as PEM, for certificate_pem. NULL on
+// failure, the string must be released with pionFreeString.
+//
+//export pionGenerateCertificate
+func pionGenerateCertificate(validityDays C.int) *C.char {
+	certificate, err := connection.GenerateCertificate(time.Duration(validityDays) * 24 * time.Hour)
+	if err != nil {
+		LogError("Failed to generate certificate: " + err.Error())
+		return nil
+	}
+
+	pems, err := certificate.PEM()
+	if err != nil {
+		LogError("Failed to export certificate: " + err.Error())
+		return nil
+	}
+
+	return C.CString(pems)
+}
+
+// Returns the DTLS certificate of the connection with its private key as
+// PEM, or NULL when there is no connection. The string must be released with
+// pionFreeString.
+//
+//export pionGetCertificate
+func pionGetCertificate() *C.char {
+	if pionConnection != nil {
+		certificate, err := pionConnection.Certificate()
+		if err != nil {
+			LogError("Failed to get certificate: " + err.Error())
+			return nil
+		}
+
+		pems, err := certificate.PEM()
+		if err != nil {
+			LogError("Failed to export certificate: " + err.Error())
+			return nil
+		}
+
+		return C.CString(pems)
+	}
+
+	return nil
+}
+
+// Returns the SHA-256 fingerprint of a PEM certificate as announced in
+// a=fingerprint, e.g. "sha-256 AB:CD:...", or NULL when the PEM is invalid.
+// The string must be released with pionFreeString.
+//
+//export pionGetCertificateFingerprint
+func pionGetCertificateFingerprint(pems *C.char) *C.char {
+	certificate, err := connection.CertificateFromPEM(C.GoString(pems))
+	if err != nil {
+		LogError("Failed to import certificate: " + err.Error())
+		return nil
+	}
+
+	fingerprint, err := certificate.Fingerprint()
+	if err != nil {
+		LogError("Failed to get certificate fingerprint: " + err.Error())
+		return nil
+	}
+
+	return C.CString(fingerprint)
+}
+
+//export pionFreeString
+func pionFreeString(str *C.char) {
+	C.free(unsafe.Pointer(str))
+}
+
 // ============================================================================
 // Go implementation
 // ============================================================================
 
-func createPeerConnectionConfig(config *C.PionPeerConnectionConfiguration) webrtc.Configuration {
+func createPeerConnectionConfig(config *C.PionPeerConnectionConfiguration) (webrtc.Configuration, error) {
 	if config == nil {
-		return webrtc.Configuration{}
+		return webrtc.Configuration{}, nil
 	}
 
-	struct_size := unsafe.Sizeof(*config.ice_servers)
-	num_servers := int(config.num_servers)
+	pionConfig := webrtc.Configuration{
+		ICEServers:         createICEServers(config.ice_servers, config.num_servers),
+		ICETransportPolicy: webrtc.ICETransportPolicy(config.ice_transport_policy),
+		BundlePolicy:       webrtc.BundlePolicy(config.bundle_policy),
+		RTCPMuxPolicy:      webrtc.RTCPMuxPolicy(config.rtcp_mux_policy),
+	}
 
-	pion_servers := []webrtc.ICEServer{}
+	if config.certificate_pem != nil {
+		certificate, err := connection.CertificateFromPEM(C.GoString(config.certificate_pem))
+		if err != nil {
+			return pionConfig, err
+		}
+		pionConfig.Certificates = []webrtc.Certificate{certificate.WebRTCCertificate()}
+	}
 
-	var hostname string
-	var username string
-	var credential string
+	return pionConfig, nil
+}
 
-	urls := []string{}
+func createICEServers(servers *C.PionIceServer, numServers C.int) []webrtc.ICEServer {
+	struct_size := unsafe.Sizeof(*servers)
+	num_servers := int(numServers)
+
+	pion_servers := []webrtc.ICEServer{}
 
 	for i := 0; i < num_servers; i++ {
-		server := (*C.PionIceServer)(unsafe.Pointer(uintptr(unsafe.Pointer(config.ice_servers)) + uintptr(i)*struct_size))
-		if server != nil {
-			hostname = C.GoString(server.hostname)
-			username = C.GoString(server.username)
-			credential = C.GoString(server.credential)
-
-			//LogInfo("ICE server: " + hostname + " username: " + username + " password: " + credential)
-
-			urls = append(urls, hostname)
-			pion_servers = append(pion_servers, webrtc.ICEServer{
-				URLs:           urls,
-				Username:       username,
-				Credential:     credential,
-				CredentialType: webrtc.ICECredentialTypePassword,
-			})
+		server := (*C.PionIceServer)(unsafe.Pointer(uintptr(unsafe.Pointer(servers)) + uintptr(i)*struct_size))
+		if server == nil {
+			continue
+		}
+
+		// every server has its own list of URLs
+		urls := []string{}
+		for _, url := range splitList(server.hostname) {
+			if url = strings.TrimSpace(url); url != "" {
+				urls = append(urls, url)
+			}
+		}
+
+		iceServer := webrtc.ICEServer{
+			URLs:           urls,
+			Username:       C.GoString(server.username),
+			Credential:     C.GoString(server.credential),
+			CredentialType: webrtc.ICECredentialType(server.credential_type),
+		}
+		if iceServer.CredentialType == webrtc.ICECredentialTypeOauth {
+			iceServer.Credential = webrtc.OAuthCredential{
+				MACKey:      C.GoString(server.mac_key),
+				AccessToken: C.GoString(server.credential),
+			}
 		}
+		pion_servers = append(pion_servers, iceServer)
 	}
 
-	return webrtc.Configuration{ICEServers: pion_servers}
+	return pion_servers
+}
+
+// splitList splits a comma separated list, NULL gives an empty list.
+func splitList(list *C.char) []string {
+	if list == nil {
+		return nil
+	}
+
+	return strings.Split(C.GoString(list), ",")
+}
+
+// networkTypes converts PionNetworkType flags, no flags give an empty list.
+func networkTypes(flags C.int) []webrtc.NetworkType {
+	types := []webrtc.NetworkType{}
+	if flags&C.PionNetworkTypeUDP4 != 0 {
+		types = append(types, webrtc.NetworkTypeUDP4)
+	}
+	if flags&C.PionNetworkTypeUDP6 != 0 {
+		types = append(types, webrtc.NetworkTypeUDP6)
+	}
+	if flags&C.PionNetworkTypeTCP4 != 0 {
+		types = append(types, webrtc.NetworkTypeTCP4)
+	}
+	if flags&C.PionNetworkTypeTCP6 != 0 {
+		types = append(types, webrtc.NetworkTypeTCP6)
+	}
+
+	return types
+}
+
+func createPeerConnectionSettings(config *C.PionPeerConnectionConfiguration) connection.WebRTCSettings {
+	if config == nil {
+		return connection.WebRTCSettings{}
+	}
+
+	return connection.WebRTCSettings{
+		JitterBuffer: connection.JitterBufferSettings{
+			Enabled:  config.jitter_buffer_enabled != 0,
+			MinDelay: time.Duration(config.jitter_buffer_min_delay_ms) * time.Millisecond,
+			MaxDelay: time.Duration(config.jitter_buffer_max_delay_ms) * time.Millisecond,
+		},
+		QualityMonitor: connection.QualityMonitorSettings{
+			Enabled:          config.quality_monitor_enabled != 0,
+			Interval:         time.Duration(config.quality_monitor_interval_ms) * time.Millisecond,
+			MaxPacketLoss:    float64(config.quality_max_packet_loss_percent) / 100,
+			MaxJitter:        time.Duration(config.quality_max_jitter_ms) * time.Millisecond,
+			MaxRoundTripTime: time.Duration(config.quality_max_rtt_ms) * time.Millisecond,
+			MinPacketRate:    int(config.quality_min_packet_rate),
+		},
+		Interceptors: connection.InterceptorFlags(config.interceptors),
+		BandwidthEstimation: connection.BandwidthEstimationSettings{
+			Enabled:        config.bandwidth_estimation_enabled != 0,
+			InitialBitrate: int(config.bwe_initial_bitrate),
+			MinBitrate:     int(config.bwe_min_bitrate),
+			MaxBitrate:     int(config.bwe_max_bitrate),
+		},
+		AudioDirection:     webrtc.RTPTransceiverDirection(config.audio_direction),
+		PerfectNegotiation: config.perfect_negotiation != 0,
+		Polite:             config.polite != 0,
+		SimulcastLayer:     connection.SimulcastLayerMode(config.simulcast_layer),
+		Network: connection.NetworkSettings{
+			PortMin:              uint16(config.ice_port_min),
+			PortMax:              uint16(config.ice_port_max),
+			UDPMuxPort:           int(config.ice_udp_mux_port),
+			TCPMuxPort:           int(config.ice_tcp_mux_port),
+			Lite:                 config.ice_lite != 0,
+			NAT1To1IPs:           splitList(config.nat_1to1_ips),
+			NAT1To1CandidateType: webrtc.ICECandidateType(config.nat_1to1_candidate_type),
+			NetworkTypes:         networkTypes(config.ice_network_types),
+			InterfaceAllowList:   splitList(config.ice_interface_allow_list),
+			InterfaceDenyList:    splitList(config.ice_interface_deny_list),
+			IPAllowList:          splitList(config.ice_ip_allow_list),
+			IPDenyList:           splitList(config.ice_ip_deny_list),
+			Timeouts: connection.ICETimeouts{
+				Disconnected:           time.Duration(config.ice_disconnected_timeout_ms) * time.Millisecond,
+				Failed:                 time.Duration(config.ice_failed_timeout_ms) * time.Millisecond,
+				Keepalive:              time.Duration(config.ice_keepalive_interval_ms) * time.Millisecond,
+				HostAcceptanceMinWait:  time.Duration(config.ice_host_acceptance_min_wait_ms) * time.Millisecond,
+				SrflxAcceptanceMinWait: time.Duration(config.ice_srflx_acceptance_min_wait_ms) * time.Millisecond,
+				PrflxAcceptanceMinWait: time.Duration(config.ice_prflx_acceptance_min_wait_ms) * time.Millisecond,
+				RelayAcceptanceMinWait: time.Duration(config.ice_relay_acceptance_min_wait_ms) * time.Millisecond,
+			},
+		},
+		TURNREST: connection.TURNRESTSettings{
+			URLs:   splitList(config.turn_rest_urls),
+			Secret: C.GoString(config.turn_rest_secret),
+			UserID: C.GoString(config.turn_rest_user_id),
+			TTL:    time.Duration(config.turn_rest_ttl_s) * time.Second,
+		},
+		UseTURNServer:           config.use_turn_server != 0,
+		CandidatesInDescription: config.local_description_with_candidates != 0,
+	}
 }
 
 func main() {